@@ -12,16 +12,43 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/athena/types"
 )
 
+// AthenaAPI is the subset of the Athena client used by this tool, it is
+// satisfied by *athena.Client and by FakeAthena
+type AthenaAPI interface {
+	StartQueryExecution(ctx context.Context, params *athena.StartQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error)
+	GetQueryExecution(ctx context.Context, params *athena.GetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error)
+	GetQueryResults(ctx context.Context, params *athena.GetQueryResultsInput, optFns ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error)
+	GetWorkGroup(ctx context.Context, params *athena.GetWorkGroupInput, optFns ...func(*athena.Options)) (*athena.GetWorkGroupOutput, error)
+	StopQueryExecution(ctx context.Context, params *athena.StopQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StopQueryExecutionOutput, error)
+	CreatePreparedStatement(ctx context.Context, params *athena.CreatePreparedStatementInput, optFns ...func(*athena.Options)) (*athena.CreatePreparedStatementOutput, error)
+	GetPreparedStatement(ctx context.Context, params *athena.GetPreparedStatementInput, optFns ...func(*athena.Options)) (*athena.GetPreparedStatementOutput, error)
+	ListPreparedStatements(ctx context.Context, params *athena.ListPreparedStatementsInput, optFns ...func(*athena.Options)) (*athena.ListPreparedStatementsOutput, error)
+	DeletePreparedStatement(ctx context.Context, params *athena.DeletePreparedStatementInput, optFns ...func(*athena.Options)) (*athena.DeletePreparedStatementOutput, error)
+	ListTableMetadata(ctx context.Context, params *athena.ListTableMetadataInput, optFns ...func(*athena.Options)) (*athena.ListTableMetadataOutput, error)
+	ListQueryExecutions(ctx context.Context, params *athena.ListQueryExecutionsInput, optFns ...func(*athena.Options)) (*athena.ListQueryExecutionsOutput, error)
+	BatchGetQueryExecution(ctx context.Context, params *athena.BatchGetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.BatchGetQueryExecutionOutput, error)
+}
+
+var _ AthenaAPI = (*athena.Client)(nil)
+
+// DefaultCatalog is the Glue data catalog every account has
+const DefaultCatalog string = "AwsDataCatalog"
+
 type QuerySummary struct {
-	Successful bool
-	Stats      *types.QueryExecutionStatistics
-	StmtType   string
+	Successful     bool
+	State          string
+	Stats          *types.QueryExecutionStatistics
+	StmtType       string
+	OutputLocation string
+	Execution      *types.QueryExecution // the last state seen of the query
 }
 
-func GetSchema(database string, workGroup string, cfg aws.Config, ctx context.Context) (string, error) {
+// GetSchema prints the DDL of every view and table in the current database
+func (s *Session) GetSchema(ctx context.Context) (string, error) {
+	database := s.Database
 	// get views
 	getViewsSql := fmt.Sprintf("select table_name, view_definition from information_schema.views where table_schema='%s'", database)
-	rows, _, err := RunQueryAndGetResults(getViewsSql, cfg, ctx)
+	rows, _, err := s.RunQueryAndGetResults(getViewsSql, ctx)
 	if err != nil {
 		return "", err
 	}
@@ -34,14 +61,14 @@ func GetSchema(database string, workGroup string, cfg aws.Config, ctx context.Co
 	}
 	// get tables
 	getTablesSql := fmt.Sprintf("select table_name from information_schema.tables where table_schema='%s'", database)
-	rows, _, err = RunQueryAndGetResults(getTablesSql, cfg, ctx)
+	rows, _, err = s.RunQueryAndGetResults(getTablesSql, ctx)
 	if err != nil {
 		return "", err
 	}
 	for _, table := range rows[1:] {
 		if _, exists := views[*table.Data[0].VarCharValue]; !exists {
 			getCreateTableSql := fmt.Sprintf("show create table %s", *table.Data[0].VarCharValue)
-			lines, _, getCreateTableErr := RunQueryAndGetResults(getCreateTableSql, cfg, ctx)
+			lines, _, getCreateTableErr := s.RunQueryAndGetResults(getCreateTableSql, ctx)
 			if getCreateTableErr != nil {
 				return "", getCreateTableErr
 			}
@@ -54,17 +81,21 @@ func GetSchema(database string, workGroup string, cfg aws.Config, ctx context.Co
 	return "", nil
 }
 
-func RunQueryAndGetResults(sql string, cfg aws.Config, ctx context.Context) ([]types.Row, []types.ColumnInfo, error) {
-	queryId, queryErr := StartQueryExec(sql, workGroup, database, cfg, ctx)
+// RunQueryAndGetResults runs a query the tool needs for itself in the current
+// database and returns all its results, it is still written to the query log
+func (s *Session) RunQueryAndGetResults(sql string, ctx context.Context) ([]types.Row, []types.ColumnInfo, error) {
+	queryId, queryErr := StartQueryWithRetry(sql, s.WorkGroup, s.Catalog, s.Database, nil, s.MaxPollInterval, s.Client, ctx)
 	if queryErr != nil {
+		s.LogQuery(NewQueryLogEntry(s.WorkGroup, s.Catalog, s.Database, "", sql, QuerySummary{}, queryErr))
 		return nil, nil, queryErr
 	}
-	queryRes, monitorErr := MonitorQuery(queryId, cfg, ctx)
+	queryRes, monitorErr := MonitorQuery(queryId, s.MaxPollInterval, nil, s.Client, ctx)
+	s.LogQuery(NewQueryLogEntry(s.WorkGroup, s.Catalog, s.Database, queryId, sql, queryRes, monitorErr))
 	if monitorErr != nil {
 		return nil, nil, monitorErr
 	}
 	if queryRes.Successful {
-		rows, columns, getResultsErr := GetQueryResults(queryId, cfg, ctx)
+		rows, columns, getResultsErr := GetQueryResults(queryId, s.Client, ctx)
 		if getResultsErr != nil {
 			return nil, nil, getResultsErr
 		}
@@ -74,8 +105,8 @@ func RunQueryAndGetResults(sql string, cfg aws.Config, ctx context.Context) ([]t
 	}
 }
 
-func CheckWorkGroup(workGroup string, cfg aws.Config, ctx context.Context) (bool, error) {
-	wg, err := GetWorkGroup(workGroup, cfg, ctx)
+func CheckWorkGroup(workGroup string, client AthenaAPI, ctx context.Context) (bool, error) {
+	wg, err := GetWorkGroup(workGroup, client, ctx)
 	if err != nil {
 		return false, err
 	}
@@ -86,9 +117,7 @@ func CheckWorkGroup(workGroup string, cfg aws.Config, ctx context.Context) (bool
 	}
 }
 
-func GetWorkGroup(workGroup string, cfg aws.Config, ctx context.Context) (athena.GetWorkGroupOutput, error) {
-	client := athena.NewFromConfig(cfg)
-
+func GetWorkGroup(workGroup string, client AthenaAPI, ctx context.Context) (athena.GetWorkGroupOutput, error) {
 	var gwgi athena.GetWorkGroupInput
 	gwgi.WorkGroup = aws.String(workGroup)
 
@@ -100,15 +129,17 @@ func GetWorkGroup(workGroup string, cfg aws.Config, ctx context.Context) (athena
 
 }
 
-func StartQueryExec(query string, workgroup string, database string, cfg aws.Config, ctx context.Context) (string, error) {
-	client := athena.NewFromConfig(cfg)
-
+func StartQueryExec(query string, workgroup string, catalog string, database string, params []string, client AthenaAPI, ctx context.Context) (string, error) {
 	var qei athena.StartQueryExecutionInput
 	qei.WorkGroup = aws.String(workgroup)
 	qei.QueryString = aws.String(query)
+	qei.ExecutionParameters = params
 
 	var qec types.QueryExecutionContext
-	qec.Catalog = aws.String("AwsDataCatalog")
+	if catalog == "" {
+		catalog = DefaultCatalog
+	}
+	qec.Catalog = aws.String(catalog)
 	qec.Database = aws.String(database)
 
 	qei.QueryExecutionContext = &qec
@@ -121,27 +152,62 @@ func StartQueryExec(query string, workgroup string, database string, cfg aws.Con
 	return *queryExecution.QueryExecutionId, nil
 }
 
-func MonitorQuery(execId string, cfg aws.Config, ctx context.Context) (QuerySummary, error) {
-	client := athena.NewFromConfig(cfg)
+// StartQueryWithRetry starts a query, waiting and trying again while Athena
+// says too many queries are running
+func StartQueryWithRetry(query string, workgroup string, catalog string, database string, params []string, maxPoll time.Duration, client AthenaAPI, ctx context.Context) (string, error) {
+	backoff := NewBackoff(maxPoll)
+	for {
+		id, err := StartQueryExec(query, workgroup, catalog, database, params, client, ctx)
+		if err == nil || !IsThrottlingError(err) {
+			return id, err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff.Next()):
+		}
+	}
+}
 
+// MonitorQuery waits for a query to finish, polling quickly at first and then
+// backing off up to maxPoll.  onPoll (if not nil) is called with the state of
+// the query each time it is checked and if it returns an error monitoring
+// stops with that error
+func MonitorQuery(execId string, maxPoll time.Duration, onPoll func(exec *types.QueryExecution) error, client AthenaAPI, ctx context.Context) (QuerySummary, error) {
 	check := true
 
 	var gqei athena.GetQueryExecutionInput
 	gqei.QueryExecutionId = aws.String(execId)
 
 	var res QuerySummary
+	backoff := NewBackoff(maxPoll)
 
 	for check {
 		resp, err := client.GetQueryExecution(ctx, &gqei)
+		if err != nil && IsThrottlingError(err) {
+			// being throttled is not a failure of the query, so try again later
+			select {
+			case <-ctx.Done():
+				res.Successful = false
+				return res, ctx.Err()
+			case <-time.After(backoff.Next()):
+			}
+			continue
+		}
 		if err != nil {
 			res.Successful = false
 			return res, err
 		}
 		state := resp.QueryExecution.Status.State
+		res.State = string(state)
 		stmtType := resp.QueryExecution.StatementType
 		res.StmtType = string(stmtType)
+		res.Stats = resp.QueryExecution.Statistics
+		res.Execution = resp.QueryExecution
 		if state == "SUCCEEDED" {
-			res.Stats = resp.QueryExecution.Statistics
+			if resp.QueryExecution.ResultConfiguration != nil {
+				res.OutputLocation = aws.ToString(resp.QueryExecution.ResultConfiguration.OutputLocation)
+			}
 			res.Successful = true
 			return res, nil
 		}
@@ -153,40 +219,175 @@ func MonitorQuery(execId string, cfg aws.Config, ctx context.Context) (QuerySumm
 			res.Successful = false
 			return res, errors.New("query was cancelled by user")
 		}
-		time.Sleep(2 * time.Second)
+		if onPoll != nil {
+			pollErr := onPoll(resp.QueryExecution)
+			if pollErr != nil {
+				res.Successful = false
+				return res, pollErr
+			}
+		}
+		select {
+		case <-ctx.Done():
+			res.Successful = false
+			return res, ctx.Err()
+		case <-time.After(backoff.Next()):
+		}
 	}
 	res.Successful = false
 	return res, errors.New("could not get response")
 }
 
-func GetQueryResults(execId string, cfg aws.Config, ctx context.Context) ([]types.Row, []types.ColumnInfo, error) {
-	client := athena.NewFromConfig(cfg)
+// ListQueryHistory calls visit with the queries run in a workgroup, newest
+// first, until it returns false or there are no more
+func ListQueryHistory(workGroup string, visit func(exec types.QueryExecution) bool, client AthenaAPI, ctx context.Context) error {
+	paginator := athena.NewListQueryExecutionsPaginator(client, &athena.ListQueryExecutionsInput{
+		WorkGroup:  aws.String(workGroup),
+		MaxResults: aws.Int32(50),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if len(resp.QueryExecutionIds) == 0 {
+			continue
+		}
+		batch, err := client.BatchGetQueryExecution(ctx, &athena.BatchGetQueryExecutionInput{
+			QueryExecutionIds: resp.QueryExecutionIds,
+		})
+		if err != nil {
+			return err
+		}
+		// the batch isn't in any particular order, so put it back in the listed order
+		execs := map[string]types.QueryExecution{}
+		for _, exec := range batch.QueryExecutions {
+			execs[aws.ToString(exec.QueryExecutionId)] = exec
+		}
+		for _, id := range resp.QueryExecutionIds {
+			exec, exists := execs[id]
+			if exists && !visit(exec) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
 
-	gqri := &athena.GetQueryResultsInput{
-		QueryExecutionId: aws.String(execId),
+func GetQueryExecution(execId string, client AthenaAPI, ctx context.Context) (*types.QueryExecution, error) {
+	var gqei athena.GetQueryExecutionInput
+	gqei.QueryExecutionId = aws.String(execId)
+
+	resp, err := client.GetQueryExecution(ctx, &gqei)
+	if err != nil {
+		return nil, err
 	}
+	return resp.QueryExecution, nil
+}
 
-	var allRows []types.Row
-	var columnInfo []types.ColumnInfo
+func StopQuery(execId string, client AthenaAPI, ctx context.Context) error {
+	var sqei athena.StopQueryExecutionInput
+	sqei.QueryExecutionId = aws.String(execId)
+
+	_, err := client.StopQueryExecution(ctx, &sqei)
+	return err
+}
+
+func CreatePreparedStatement(name string, query string, workGroup string, client AthenaAPI, ctx context.Context) error {
+	var cpsi athena.CreatePreparedStatementInput
+	cpsi.StatementName = aws.String(name)
+	cpsi.QueryStatement = aws.String(query)
+	cpsi.WorkGroup = aws.String(workGroup)
+
+	_, err := client.CreatePreparedStatement(ctx, &cpsi)
+	return err
+}
+
+func GetPreparedStatement(name string, workGroup string, client AthenaAPI, ctx context.Context) (types.PreparedStatement, error) {
+	var gpsi athena.GetPreparedStatementInput
+	gpsi.StatementName = aws.String(name)
+	gpsi.WorkGroup = aws.String(workGroup)
+
+	resp, err := client.GetPreparedStatement(ctx, &gpsi)
+	if err != nil {
+		return types.PreparedStatement{}, err
+	}
+	return *resp.PreparedStatement, nil
+}
+
+func ListPreparedStatements(workGroup string, client AthenaAPI, ctx context.Context) ([]types.PreparedStatementSummary, error) {
+	var statements []types.PreparedStatementSummary
 
-	loop := true
-	for loop {
-		resp, err := client.GetQueryResults(ctx, gqri)
+	paginator := athena.NewListPreparedStatementsPaginator(client, &athena.ListPreparedStatementsInput{
+		WorkGroup: aws.String(workGroup),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
 		if err != nil {
-			return allRows, columnInfo, err
+			return statements, err
 		}
+		statements = append(statements, resp.PreparedStatements...)
+	}
+	return statements, nil
+}
 
-		columnInfo = resp.ResultSet.ResultSetMetadata.ColumnInfo
-		allRows = append(allRows, resp.ResultSet.Rows...)
+func DeletePreparedStatement(name string, workGroup string, client AthenaAPI, ctx context.Context) error {
+	var dpsi athena.DeletePreparedStatementInput
+	dpsi.StatementName = aws.String(name)
+	dpsi.WorkGroup = aws.String(workGroup)
 
-		// check if we should keep getting results
-		if resp.NextToken != nil {
-			gqri.NextToken = resp.NextToken
-		} else {
-			loop = false
+	_, err := client.DeletePreparedStatement(ctx, &dpsi)
+	return err
+}
+
+func ListTableMetadata(catalog string, database string, client AthenaAPI, ctx context.Context) ([]types.TableMetadata, error) {
+	var tables []types.TableMetadata
+
+	paginator := athena.NewListTableMetadataPaginator(client, &athena.ListTableMetadataInput{
+		CatalogName:  aws.String(catalog),
+		DatabaseName: aws.String(database),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return tables, err
 		}
+		tables = append(tables, resp.TableMetadataList...)
 	}
+	return tables, nil
+}
+
+func GetQueryResults(execId string, client AthenaAPI, ctx context.Context) ([]types.Row, []types.ColumnInfo, error) {
+	var allRows []types.Row
+	var columnInfo []types.ColumnInfo
+
+	err := StreamQueryResults(execId, client, ctx, func(rows []types.Row, columns []types.ColumnInfo) error {
+		columnInfo = columns
+		allRows = append(allRows, rows...)
+		return nil
+	})
 
-	return allRows, columnInfo, nil
+	return allRows, columnInfo, err
+}
+
+// StreamQueryResults fetches the results one page at a time and passes each
+// page to handlePage as it arrives, only the first page has the column names row
+func StreamQueryResults(execId string, client AthenaAPI, ctx context.Context, handlePage func(rows []types.Row, columns []types.ColumnInfo) error) error {
+	gqri := &athena.GetQueryResultsInput{
+		QueryExecutionId: aws.String(execId),
+	}
+
+	paginator := athena.NewGetQueryResultsPaginator(client, gqri)
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = handlePage(resp.ResultSet.Rows, resp.ResultSet.ResultSetMetadata.ColumnInfo)
+		if err != nil {
+			return err
+		}
+	}
 
+	return nil
 }
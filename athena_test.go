@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/smithy-go"
+)
+
+// throttledAthena rejects the first few queries as if too many were running
+type throttledAthena struct {
+	*FakeAthena
+	rejections int
+}
+
+func (f *throttledAthena) StartQueryExecution(ctx context.Context, params *athena.StartQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error) {
+	if f.rejections > 0 {
+		f.rejections--
+		return nil, &smithy.GenericAPIError{Code: "TooManyRequestsException", Message: "too many queries"}
+	}
+	return f.FakeAthena.StartQueryExecution(ctx, params, optFns...)
+}
+
+func TestQueriesRetriedWhenThrottled(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(s *Session)
+	}{
+		{"interactive", func(s *Session) { s.SendLine("select 1;") }},
+		{"background", func(s *Session) {
+			s.SendLine("select 1 &")
+			for _, job := range s.jobs {
+				<-job.done
+			}
+		}},
+		{"schema", func(s *Session) { s.RunQueryAndGetResults("select 1", s.Ctx) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestSession(t)
+			s.Client = &throttledAthena{FakeAthena: fake, rejections: 2}
+			tt.run(s)
+			if len(fake.Started) != 1 {
+				t.Errorf("%d queries started, want 1", len(fake.Started))
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.Client = &throttledAthena{FakeAthena: fake, rejections: 1000}
+	interruptWhenRunning(s)
+	s.SendLine("select 1;")
+	if !s.cancelled {
+		t.Error("query was not cancelled")
+	}
+	if len(fake.Started) != 0 {
+		t.Errorf("%d queries started, want 0", len(fake.Started))
+	}
+}
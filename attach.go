@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// AttachQuery follows a query started somewhere else until it finishes and
+// then shows its results.  The query isn't ours so Ctrl-C or a timeout stop
+// waiting for it rather than stopping it
+func (s *Session) AttachQuery(execId string) error {
+	queryCtx := s.startQuery()
+	defer s.finishQuery()
+
+	progress := NewProgressLine(os.Stdout, s.ShowProgress)
+	queryRes, monitorErr := MonitorQuery(execId, s.MaxPollInterval, func(exec *types.QueryExecution) error {
+		progress.Update(exec, s.PricePerTB)
+		return nil
+	}, s.Client, queryCtx)
+	progress.Clear()
+	if errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query %s is still running after waiting %s", execId, s.Timeout)
+	}
+	if queryCtx.Err() != nil {
+		fmt.Printf("Stopped waiting, query %s is still running\n", execId)
+		return nil
+	}
+	if monitorErr != nil {
+		return monitorErr
+	}
+
+	var scanned, runtime int64
+	if queryRes.Stats != nil {
+		scanned = aws.ToInt64(queryRes.Stats.DataScannedInBytes)
+		runtime = aws.ToInt64(queryRes.Stats.EngineExecutionTimeInMillis)
+	}
+	if s.FullStats {
+		PrintFullStats(queryRes.Execution, EstimateCost(scanned, s.PricePerTB))
+	} else if s.ShowStats {
+		fmt.Printf("Stats: bytes scanned: %v, runtime: %v\n", scanned, runtime)
+	}
+	resultsErr := s.OutputQueryResults(execId, queryRes, queryCtx)
+	if queryCtx.Err() != nil {
+		fmt.Println("Fetching results cancelled")
+		return nil
+	}
+	return resultsErr
+}
+
+// ShowStatus prints the state, SQL and statistics of any query
+func (s *Session) ShowStatus(execId string) error {
+	exec, err := GetQueryExecution(execId, s.Client, s.Ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Query id:  %s\n", execId)
+	if exec.Status != nil {
+		fmt.Printf("State:     %s\n", exec.Status.State)
+		if reason := aws.ToString(exec.Status.StateChangeReason); reason != "" {
+			fmt.Printf("Reason:    %s\n", reason)
+		}
+		if exec.Status.SubmissionDateTime != nil {
+			fmt.Printf("Submitted: %s\n", exec.Status.SubmissionDateTime.Local().Format(time.RFC3339))
+		}
+		if exec.Status.CompletionDateTime != nil {
+			fmt.Printf("Completed: %s\n", exec.Status.CompletionDateTime.Local().Format(time.RFC3339))
+		}
+	}
+	if exec.QueryExecutionContext != nil {
+		fmt.Printf("Catalog:   %s\n", aws.ToString(exec.QueryExecutionContext.Catalog))
+		fmt.Printf("Database:  %s\n", aws.ToString(exec.QueryExecutionContext.Database))
+	}
+	fmt.Println("SQL:")
+	for _, line := range strings.Split(aws.ToString(exec.Query), "\n") {
+		fmt.Printf("  %s\n", line)
+	}
+
+	var scanned int64
+	if exec.Statistics != nil {
+		scanned = aws.ToInt64(exec.Statistics.DataScannedInBytes)
+	}
+	PrintFullStats(exec, EstimateCost(scanned, s.PricePerTB))
+	return nil
+}
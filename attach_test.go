@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttachWithoutStats(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		s, fake := newTestSession(t)
+		s.SendLine("select 1;")
+		s.Client = &noStatsAthena{fake}
+		s.ShowStats, s.FullStats = true, full
+		out := captureOutput(t, func() {
+			if err := s.AttachQuery("fake-00000001"); err != nil {
+				t.Error(err)
+			}
+		})
+		if !strings.Contains(out, noStatsLines[full]) {
+			t.Errorf("printed %q, want %q", out, noStatsLines[full])
+		}
+	}
+}
+
+func TestShowStatusCatalog(t *testing.T) {
+	s, _ := newTestSession(t)
+	captureOutput(t, func() {
+		s.SendLine(".use federated.sales")
+		s.SendLine("select 1;")
+	})
+	out := captureOutput(t, func() {
+		if err := s.ShowStatus("fake-00000001"); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, want := range []string{"Catalog:   federated\n", "Database:  sales\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("printed %q, want %q in it", out, want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StatementCategory is the kind of change a statement can make
+type StatementCategory int
+
+const (
+	CategoryOther       StatementCategory = iota // anything not recognised, e.g. USE or EXECUTE
+	CategoryRead                                 // SELECT, SHOW, DESCRIBE, EXPLAIN
+	CategoryDDL                                  // CREATE, ALTER, DROP
+	CategoryDML                                  // INSERT, UPDATE, DELETE, MERGE
+	CategoryCTAS                                 // CREATE TABLE ... AS SELECT
+	CategoryUnload                               // UNLOAD
+	CategoryMsck                                 // MSCK REPAIR TABLE
+	CategoryMaintenance                          // OPTIMIZE, VACUUM
+)
+
+func (c StatementCategory) String() string {
+	switch c {
+	case CategoryRead:
+		return "read-only"
+	case CategoryDDL:
+		return "DDL"
+	case CategoryDML:
+		return "DML"
+	case CategoryCTAS:
+		return "CTAS"
+	case CategoryUnload:
+		return "UNLOAD"
+	case CategoryMsck:
+		return "MSCK"
+	case CategoryMaintenance:
+		return "OPTIMIZE/VACUUM"
+	}
+	return "other"
+}
+
+// categoryNames are the names used for categories in the config file
+var categoryNames = map[string]StatementCategory{
+	"ddl":         CategoryDDL,
+	"dml":         CategoryDML,
+	"ctas":        CategoryCTAS,
+	"unload":      CategoryUnload,
+	"msck":        CategoryMsck,
+	"maintenance": CategoryMaintenance,
+	"other":       CategoryOther,
+}
+
+// ParseCategory converts a category name from the config file
+func ParseCategory(name string) (StatementCategory, error) {
+	category, exists := categoryNames[strings.ToLower(name)]
+	if !exists {
+		return CategoryOther, fmt.Errorf("unknown statement category '%s'", name)
+	}
+	return category, nil
+}
+
+// sqlToken is a word from a statement along with how deep in parentheses it
+// is, string literals and quoted identifiers become a single token whose word
+// is two single quotes
+type sqlToken struct {
+	word  string
+	text  string
+	depth int
+	pos   int // where the token starts in the statement
+}
+
+// ClassifyStatement works out the category of a single statement, leading
+// comments, parentheses and WITH clauses are skipped over
+func ClassifyStatement(sql string) StatementCategory {
+	tokens := tokenizeSql(sql)
+	if len(tokens) == 0 {
+		return CategoryOther
+	}
+	return classifyTokens(tokens)
+}
+
+func classifyTokens(tokens []sqlToken) StatementCategory {
+	switch tokens[0].word {
+	case "SELECT", "VALUES", "TABLE", "SHOW", "DESCRIBE", "DESC":
+		return CategoryRead
+	case "EXPLAIN":
+		// EXPLAIN ANALYZE runs the statement so it is whatever that is
+		rest := tokens[1:]
+		if len(rest) > 0 && rest[0].word == "ANALYZE" {
+			rest = rest[1:]
+			if len(rest) > 0 && rest[0].word == "VERBOSE" {
+				rest = rest[1:]
+			}
+			if len(rest) > 0 {
+				return classifyTokens(rest)
+			}
+		}
+		return CategoryRead
+	case "WITH":
+		// skip the named queries to find the statement they are used by
+		for i, tok := range tokens[1:] {
+			if tok.depth == tokens[0].depth {
+				switch tok.word {
+				case "SELECT", "VALUES", "TABLE", "INSERT", "UPDATE", "DELETE", "MERGE":
+					return classifyTokens(tokens[i+1:])
+				}
+			}
+		}
+		// without a statement we know it could be anything, so it is not
+		// treated as a read
+		return CategoryOther
+	case "CREATE":
+		if isCtas(tokens) {
+			return CategoryCTAS
+		}
+		return CategoryDDL
+	case "ALTER", "DROP":
+		return CategoryDDL
+	case "INSERT", "UPDATE", "DELETE", "MERGE":
+		return CategoryDML
+	case "UNLOAD":
+		return CategoryUnload
+	case "MSCK":
+		return CategoryMsck
+	case "OPTIMIZE", "VACUUM":
+		return CategoryMaintenance
+	}
+	return CategoryOther
+}
+
+// isCtas reports if a CREATE statement is CREATE TABLE ... AS, views also use
+// AS but only define a query
+func isCtas(tokens []sqlToken) bool {
+	isTable := false
+	for _, tok := range tokens[1:] {
+		if tok.depth != tokens[0].depth {
+			continue
+		}
+		switch tok.word {
+		case "VIEW", "DATABASE", "SCHEMA", "FUNCTION":
+			if !isTable {
+				return false
+			}
+		case "TABLE":
+			isTable = true
+		case "AS":
+			return isTable
+		}
+	}
+	return false
+}
+
+// tokenizeSql splits a statement into upper case words and punctuation,
+// skipping comments
+func tokenizeSql(sql string) []sqlToken {
+	var tokens []sqlToken
+	depth := 0
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 3
+		case c == '\'' || c == '"' || c == '`':
+			// a doubled quote is an escaped quote, so keep going past it
+			j := i + 1
+			for j < len(sql) {
+				if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, sqlToken{word: "''", depth: depth, pos: i})
+			i = j
+		case c == '(':
+			tokens = append(tokens, sqlToken{word: "(", depth: depth, pos: i})
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+			tokens = append(tokens, sqlToken{word: ")", depth: depth, pos: i})
+		case isIdentChar(c):
+			j := i
+			for j < len(sql) && isIdentChar(sql[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{word: strings.ToUpper(sql[i:j]), text: sql[i:j], depth: depth, pos: i})
+			i = j - 1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			tokens = append(tokens, sqlToken{word: string(c), depth: depth, pos: i})
+		}
+	}
+
+	// statements wrapped in parentheses, e.g. (SELECT 1), are classified by
+	// what is inside them
+	for len(tokens) > 0 && tokens[0].word == "(" {
+		tokens = tokens[1:]
+	}
+	return tokens
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// CheckStatement returns an error if the session settings do not allow the
+// statement to run
+func (s *Session) CheckStatement(query string) error {
+	category := ClassifyStatement(query)
+
+	// a prepared statement is only as safe as the query it runs
+	prepared := ""
+	if name := executedStatementName(query); name != "" && (s.ReadOnly || !s.DdlEnabled) {
+		var err error
+		category, err = s.preparedCategory(name)
+		if err != nil {
+			return fmt.Errorf("could not check prepared statement '%s': %v", name, err)
+		}
+		prepared = fmt.Sprintf("prepared statement '%s' is %s, ", name, category)
+	}
+
+	if s.ReadOnly && category != CategoryRead && !s.ReadOnlyAllow[category] {
+		return fmt.Errorf("%s%s statements are blocked in read-only mode", prepared, category)
+	}
+
+	switch category {
+	case CategoryDDL, CategoryCTAS, CategoryMsck:
+		if !s.DdlEnabled {
+			return fmt.Errorf("%s%s statements are blocked because DDL is not enabled, use '.ddl on' to allow them", prepared, category)
+		}
+	}
+	return nil
+}
+
+// executedStatementName returns the name of the prepared statement run by an
+// EXECUTE statement, or "" if it is not one
+func executedStatementName(query string) string {
+	tokens := tokenizeSql(query)
+	if len(tokens) >= 2 && tokens[0].word == "EXECUTE" && tokens[1].text != "" {
+		return tokens[1].text
+	}
+	return ""
+}
+
+// splitBackground removes a trailing & from a statement, which asks for it to
+// be run in the background, an & in a string or comment doesn't count
+func splitBackground(query string) (string, bool) {
+	tokens := tokenizeSql(query)
+	if len(tokens) == 0 || tokens[len(tokens)-1].word != "&" {
+		return query, false
+	}
+	return strings.TrimSpace(query[:tokens[len(tokens)-1].pos]), true
+}
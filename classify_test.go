@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want StatementCategory
+	}{
+		{"select 1", CategoryRead},
+		{"  SELECT * FROM t", CategoryRead},
+		{"(select 1) union (select 2)", CategoryRead},
+		{"values (1)", CategoryRead},
+		{"show tables", CategoryRead},
+		{"describe t", CategoryRead},
+		{"explain select 1", CategoryRead},
+		{"explain analyze select 1", CategoryRead},
+		{"explain analyze insert into t values (1)", CategoryDML},
+		{"-- leading comment\nselect 1", CategoryRead},
+		{"/* drop table t */ select 1", CategoryRead},
+		{"with a as (select 1) select * from a", CategoryRead},
+		{"with a as (select 1) insert into t select * from a", CategoryDML},
+		{"with a as (delete from t) select 1", CategoryRead},
+		{"with a as (select 1) unload (select * from a) to 's3://b/'", CategoryOther},
+		{"with a as (select 1)", CategoryOther},
+		{"insert into t values (1)", CategoryDML},
+		{"update t set a = 1", CategoryDML},
+		{"delete from t", CategoryDML},
+		{"merge into t using s on t.a = s.a when matched then delete", CategoryDML},
+		{"create table t (a int)", CategoryDDL},
+		{"create external table t (a int) location 's3://b/'", CategoryDDL},
+		{"create table t as select 1", CategoryCTAS},
+		{"create table t with (format = 'parquet') as select 1", CategoryCTAS},
+		{"create view v as select 1", CategoryDDL},
+		{"create or replace view v as select 1", CategoryDDL},
+		{"create database d", CategoryDDL},
+		{"alter table t add columns (b int)", CategoryDDL},
+		{"drop table t", CategoryDDL},
+		{"unload (select 1) to 's3://b/' with (format = 'json')", CategoryUnload},
+		{"msck repair table t", CategoryMsck},
+		{"optimize t rewrite data using bin_pack", CategoryMaintenance},
+		{"vacuum t", CategoryMaintenance},
+		{"execute p", CategoryOther},
+		{"use db", CategoryOther},
+		{"select 'drop table t'", CategoryRead},
+		{"", CategoryOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyStatement(tt.sql); got != tt.want {
+			t.Errorf("ClassifyStatement(%q) = %s, want %s", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestCheckStatementDdl(t *testing.T) {
+	tests := []struct {
+		sql     string
+		ddl     bool
+		wantErr string
+	}{
+		{"select 1", false, ""},
+		{"insert into t values (1)", false, ""},
+		{"create table t (a int)", false, "DDL statements are blocked because DDL is not enabled"},
+		{"create table t (a int)", true, ""},
+		{"create table t as select 1", false, "CTAS statements are blocked"},
+		{"msck repair table t", false, "MSCK statements are blocked"},
+		{"msck repair table t", true, ""},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		s.DdlEnabled = tt.ddl
+		checkError(t, s.CheckStatement(tt.sql), tt.wantErr)
+	}
+}
+
+func TestCheckStatementReadOnly(t *testing.T) {
+	tests := []struct {
+		sql     string
+		allow   []StatementCategory
+		wantErr string
+	}{
+		{"select 1", nil, ""},
+		{"insert into t values (1)", nil, "DML statements are blocked in read-only mode"},
+		{"insert into t values (1)", []StatementCategory{CategoryDML}, ""},
+		{"unload (select 1) to 's3://b/'", []StatementCategory{CategoryDML}, "UNLOAD statements are blocked in read-only mode"},
+		{"create table t (a int)", nil, "blocked in read-only mode"},
+		{"use db", nil, "other statements are blocked in read-only mode"},
+		{"use db", []StatementCategory{CategoryOther}, ""},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		s.ReadOnly = true
+		s.DdlEnabled = true
+		for _, category := range tt.allow {
+			s.ReadOnlyAllow[category] = true
+		}
+		checkError(t, s.CheckStatement(tt.sql), tt.wantErr)
+	}
+}
+
+func TestSplitBackground(t *testing.T) {
+	tests := []struct {
+		query      string
+		want       string
+		background bool
+	}{
+		{"select 1", "select 1", false},
+		{"select 1 &", "select 1", true},
+		{"select 1&", "select 1", true},
+		{"select '&'", "select '&'", false},
+		{"select 1 -- &", "select 1 -- &", false},
+		{"select 1 & -- later", "select 1", true},
+	}
+	for _, tt := range tests {
+		got, background := splitBackground(tt.query)
+		if got != tt.want || background != tt.background {
+			t.Errorf("splitBackground(%q) = %q, %v, want %q, %v", tt.query, got, background, tt.want, tt.background)
+		}
+	}
+}
+
+func TestParseCategory(t *testing.T) {
+	for name, want := range categoryNames {
+		got, err := ParseCategory(strings.ToUpper(name))
+		if err != nil || got != want {
+			t.Errorf("ParseCategory(%q) = %s, %v, want %s", name, got, err, want)
+		}
+	}
+	if _, err := ParseCategory("select"); err == nil {
+		t.Error("ParseCategory accepted an unknown category")
+	}
+}
+
+func TestCheckStatementPrepared(t *testing.T) {
+	tests := []struct {
+		name     string
+		prepared string
+		readOnly bool
+		ddl      bool
+		allow    []StatementCategory
+		wantErr  string
+	}{
+		{"read", "SELECT * FROM t", true, false, nil, ""},
+		{"ctas without ddl", "CREATE TABLE t2 AS SELECT * FROM t", false, false, nil, "prepared statement 'p' is CTAS"},
+		{"ctas with ddl", "CREATE TABLE t2 AS SELECT * FROM t", false, true, nil, ""},
+		{"ctas read-only", "CREATE TABLE t2 AS SELECT * FROM t", true, true, nil, "blocked in read-only mode"},
+		{"insert read-only", "INSERT INTO t VALUES (1)", true, false, nil, "prepared statement 'p' is DML"},
+		{"insert", "INSERT INTO t VALUES (1)", false, false, nil, ""},
+		{"other allowed", "INSERT INTO t VALUES (1)", true, false, []StatementCategory{CategoryOther}, "prepared statement 'p' is DML"},
+		{"other allowed ctas", "CREATE TABLE t2 AS SELECT * FROM t", true, true, []StatementCategory{CategoryOther}, "prepared statement 'p' is CTAS"},
+		{"dml allowed", "INSERT INTO t VALUES (1)", true, false, []StatementCategory{CategoryDML}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestSession(t)
+			if err := CreatePreparedStatement("p", tt.prepared, s.WorkGroup, s.Client, s.Ctx); err != nil {
+				t.Fatal(err)
+			}
+			s.ReadOnly = tt.readOnly
+			s.DdlEnabled = tt.ddl
+			for _, category := range tt.allow {
+				s.ReadOnlyAllow[category] = true
+			}
+			checkError(t, s.CheckStatement("EXECUTE p"), tt.wantErr)
+		})
+	}
+}
+
+// stalledPrepared never answers a request for a prepared statement before the
+// context ends
+type stalledPrepared struct {
+	*FakeAthena
+}
+
+func (f *stalledPrepared) GetPreparedStatement(ctx context.Context, params *athena.GetPreparedStatementInput, optFns ...func(*athena.Options)) (*athena.GetPreparedStatementOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestPreparedCheckInterrupted(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.Client = &stalledPrepared{FakeAthena: fake}
+	s.ReadOnly = true
+
+	interruptWhenRunning(s)
+	checkError(t, s.CheckStatement("EXECUTE p"), "could not check prepared statement 'p': context canceled")
+}
+
+func TestRerunChecksPreparedStatement(t *testing.T) {
+	s, fake := newTestSession(t)
+	if err := CreatePreparedStatement("p", "INSERT INTO t VALUES (1)", s.WorkGroup, s.Client, s.Ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.SendLine("EXECUTE p;")
+	s.ReadOnly = true
+	s.ReadOnlyAllow[CategoryOther] = true
+	checkError(t, s.Rerun("fake-00000001"), "prepared statement 'p' is DML")
+	if len(fake.Started) != 1 {
+		t.Errorf("%d queries were started, want 1", len(fake.Started))
+	}
+}
+
+func TestPrepareChecksStatement(t *testing.T) {
+	s, _ := newTestSession(t)
+	checkError(t, s.Prepare("p", "CREATE TABLE t2 AS SELECT * FROM t"), "DDL is not enabled")
+	if _, err := GetPreparedStatement("p", s.WorkGroup, s.Client, s.Ctx); err == nil {
+		t.Error("blocked statement was prepared")
+	}
+	s.DdlEnabled = true
+	checkError(t, s.Prepare("p", "CREATE TABLE t2 AS SELECT * FROM t"), "")
+}
+
+// checkError fails the test unless err contains want, or is nil if want is ""
+func checkError(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Errorf("got no error, want one containing %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// DotCommands are the commands offered by tab completion
+var DotCommands = []string{
+	".attach", ".bg", ".catalog", ".cost", ".ddl", ".exec", ".exit", ".fetch", ".file",
+	".header", ".help", ".history", ".jobs", ".kill", ".limit", ".log", ".mode", ".output",
+	".param", ".poll", ".prepare", ".prepared", ".quit", ".readonly", ".rerun", ".result",
+	".save", ".schema", ".stats", ".status", ".timeline", ".timeout", ".unprepare", ".use",
+	".wait", ".workgroup",
+}
+
+// commandArgs are the fixed values each command accepts, keyed by the command
+// and any earlier arguments
+var commandArgs = map[string][]string{
+	".cost":      {"price"},
+	".ddl":       {"on", "off"},
+	".fetch":     {"api", "s3"},
+	".header":    {"on", "off"},
+	".history":   {"remote"},
+	".limit":     {"scan", "session"},
+	".log":       {"search", "show"},
+	".mode":      {"ascii", "csv", "json"},
+	".mode json": {"array", "serde"},
+	".param":     {"set", "list", "clear"},
+	".readonly":  {"on", "off"},
+	".stats":     {"on", "full", "off"},
+	".timeout":   {"off"},
+}
+
+// SqlKeywords are the SQL keywords offered by tab completion
+var SqlKeywords = []string{
+	"ALL", "ALTER", "AND", "ARRAY", "AS", "ASC", "BETWEEN", "BY", "CASE", "CAST", "CREATE",
+	"CROSS", "DATABASE", "DELETE", "DESC", "DESCRIBE", "DISTINCT", "DROP", "ELSE", "END",
+	"EXCEPT", "EXECUTE", "EXISTS", "EXPLAIN", "EXTERNAL", "FALSE", "FROM", "FULL", "GROUP",
+	"HAVING", "IN", "INNER", "INSERT", "INTERSECT", "INTO", "IS", "JOIN", "LEFT", "LIKE",
+	"LIMIT", "MAP", "MERGE", "MSCK", "NOT", "NULL", "OFFSET", "ON", "OR", "ORDER", "OUTER",
+	"OVER", "PARTITION", "PARTITIONS", "PREPARE", "REPAIR", "RIGHT", "ROW", "SELECT", "SHOW",
+	"TABLE", "TABLES", "THEN", "TRUE", "UNION", "UNLOAD", "UNNEST", "UPDATE", "USING",
+	"VALUES", "VIEW", "WHEN", "WHERE", "WITH",
+}
+
+// completionTimeout is how long tab completion waits for Athena before giving
+// up, the shell can't be interrupted while it waits
+const completionTimeout time.Duration = 3 * time.Second
+
+var tableRefPattern = regexp.MustCompile(`(?i)\b(?:from|join)\s+([\w."` + "`" + `]+)`)
+
+// metadataCache holds the table metadata of one database so it is only
+// fetched once per session
+type metadataCache struct {
+	catalog  string
+	database string
+	tables   []types.TableMetadata
+}
+
+// tableMetadata returns the tables in the current database, fetching them the
+// first time they are needed
+func (s *Session) tableMetadata() []types.TableMetadata {
+	if s.metadata != nil && s.metadata.catalog == s.Catalog && s.metadata.database == s.Database {
+		return s.metadata.tables
+	}
+	ctx, cancel := context.WithTimeout(s.Ctx, completionTimeout)
+	defer cancel()
+	tables, err := ListTableMetadata(s.Catalog, s.Database, s.Client, ctx)
+	if err != nil {
+		// completion should never get in the way so errors are ignored, but
+		// the failure is cached so we don't retry on every tab
+		tables = nil
+	}
+	s.metadata = &metadataCache{catalog: s.Catalog, database: s.Database, tables: tables}
+	return tables
+}
+
+// preparedCache holds the names of the prepared statements in a workgroup so
+// they are only listed once, it is cleared when a statement is added or removed
+type preparedCache struct {
+	workGroup string
+	names     []string
+}
+
+// preparedNames returns the names of the prepared statements in the current
+// workgroup, listing them the first time they are needed
+func (s *Session) preparedNames() []string {
+	if s.prepared != nil && s.prepared.workGroup == s.WorkGroup {
+		return s.prepared.names
+	}
+	var names []string
+	ctx, cancel := context.WithTimeout(s.Ctx, completionTimeout)
+	defer cancel()
+	// as with tables a failure is cached too
+	statements, _ := ListPreparedStatements(s.WorkGroup, s.Client, ctx)
+	for _, stmt := range statements {
+		names = append(names, aws.ToString(stmt.StatementName))
+	}
+	s.prepared = &preparedCache{workGroup: s.WorkGroup, names: names}
+	return names
+}
+
+// Complete is a liner.WordCompleter, it completes the word before pos, which
+// counts runes rather than bytes
+func (s *Session) Complete(line string, pos int) (string, []string, string) {
+	runes := []rune(line)
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+	start := pos
+	for start > 0 && isWordChar(runes[start-1]) {
+		start--
+	}
+	head, word, tail := string(runes[:start]), string(runes[start:pos]), string(runes[pos:])
+
+	var candidates []string
+	if !s.Pending() && strings.HasPrefix(strings.TrimLeft(line, " "), ".") {
+		candidates = s.commandCandidates(strings.Fields(head), word)
+	} else {
+		candidates = s.sqlCandidates(s.splitter.Text()+"\n"+line, word)
+	}
+
+	var completions []string
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if !seen[c] && strings.HasPrefix(strings.ToLower(c), strings.ToLower(word)) {
+			completions = append(completions, c)
+			seen[c] = true
+		}
+	}
+	sort.Strings(completions)
+	return head, completions, tail
+}
+
+func isWordChar(c rune) bool {
+	return c == '_' || c == '.' || c == '/' || c == '-' || c == '~' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (s *Session) commandCandidates(args []string, word string) []string {
+	if len(args) == 0 {
+		return DotCommands
+	}
+	switch args[0] {
+	case ".file", ".output":
+		matches, _ := filepath.Glob(word + "*")
+		return matches
+	case ".exec", ".prepared", ".unprepare":
+		if len(args) != 1 {
+			return nil
+		}
+		return s.preparedNames()
+	}
+	return commandArgs[strings.Join(args, " ")]
+}
+
+func (s *Session) sqlCandidates(query string, word string) []string {
+	tables := s.tableMetadata()
+
+	// table.column completes the columns of that table
+	if dot := strings.LastIndex(word, "."); dot >= 0 {
+		prefix := strings.ToLower(word[:dot])
+		var candidates []string
+		for _, table := range tables {
+			name := strings.ToLower(aws.ToString(table.Name))
+			if prefix == strings.ToLower(s.Database) {
+				candidates = append(candidates, word[:dot+1]+aws.ToString(table.Name))
+			} else if prefix == name {
+				for _, col := range tableColumns(table) {
+					candidates = append(candidates, word[:dot+1]+col)
+				}
+			}
+		}
+		return candidates
+	}
+
+	// keywords follow the case the user is typing in
+	var candidates []string
+	for _, keyword := range SqlKeywords {
+		if word != "" && strings.ToLower(word) == word {
+			keyword = strings.ToLower(keyword)
+		}
+		candidates = append(candidates, keyword)
+	}
+
+	referenced := map[string]bool{}
+	for _, match := range tableRefPattern.FindAllStringSubmatch(query, -1) {
+		name := strings.Trim(match[1], "\"`")
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			name = strings.Trim(name[dot+1:], "\"`")
+		}
+		referenced[strings.ToLower(name)] = true
+	}
+	for _, table := range tables {
+		candidates = append(candidates, aws.ToString(table.Name))
+		if referenced[strings.ToLower(aws.ToString(table.Name))] {
+			candidates = append(candidates, tableColumns(table)...)
+		}
+	}
+	return candidates
+}
+
+func tableColumns(table types.TableMetadata) []string {
+	var cols []string
+	for _, col := range table.Columns {
+		cols = append(cols, aws.ToString(col.Name))
+	}
+	for _, col := range table.PartitionKeys {
+		cols = append(cols, aws.ToString(col.Name))
+	}
+	return cols
+}
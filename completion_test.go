@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestCompleteNonAscii(t *testing.T) {
+	s, fake := newTestSession(t)
+	fake.Tables["db"] = []types.TableMetadata{{Name: aws.String("orders")}}
+
+	// the cursor is after "ord", which is past the byte length of "é"
+	line := "select 'é' from ord"
+	head, completions, tail := s.Complete(line, len([]rune(line)))
+	if head != "select 'é' from " || tail != "" || !reflect.DeepEqual(completions, []string{"order", "orders"}) {
+		t.Errorf("Complete gave %q %q %q", head, completions, tail)
+	}
+
+	head, completions, tail = s.Complete("select ord 'é'", len("select ord"))
+	if head != "select " || tail != " 'é'" || !reflect.DeepEqual(completions, []string{"order", "orders"}) {
+		t.Errorf("Complete gave %q %q %q", head, completions, tail)
+	}
+}
+
+// countingAthena counts how often prepared statements are listed
+type countingAthena struct {
+	*FakeAthena
+	lists int
+}
+
+func (f *countingAthena) ListPreparedStatements(ctx context.Context, params *athena.ListPreparedStatementsInput, optFns ...func(*athena.Options)) (*athena.ListPreparedStatementsOutput, error) {
+	f.lists++
+	return f.FakeAthena.ListPreparedStatements(ctx, params, optFns...)
+}
+
+func TestPreparedNamesCached(t *testing.T) {
+	s, fake := newTestSession(t)
+	fake.WorkGroups["other"] = "s3://other/"
+	client := &countingAthena{FakeAthena: fake}
+	s.Client = client
+	captureOutput(t, func() {
+		s.Prepare("first", "select 1")
+	})
+
+	complete := func() []string {
+		_, completions, _ := s.Complete(".exec ", len(".exec "))
+		return completions
+	}
+	if got := complete(); !reflect.DeepEqual(got, []string{"first"}) {
+		t.Errorf("completions %q, want [first]", got)
+	}
+	complete()
+	if client.lists != 1 {
+		t.Errorf("prepared statements listed %d times, want 1", client.lists)
+	}
+
+	captureOutput(t, func() {
+		s.Prepare("second", "select 2")
+	})
+	if got := complete(); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("completions %q after .prepare, want [first second]", got)
+	}
+	captureOutput(t, func() {
+		s.Unprepare("first")
+	})
+	if got := complete(); !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("completions %q after .unprepare, want [second]", got)
+	}
+	captureOutput(t, func() {
+		s.SetWorkGroup("other")
+	})
+	if got := complete(); len(got) != 0 {
+		t.Errorf("completions %q after changing workgroup, want none", got)
+	}
+}
+
+// deadlineAthena records the deadline of each call tab completion makes
+type deadlineAthena struct {
+	*FakeAthena
+	deadlines map[string]time.Time
+}
+
+func (f *deadlineAthena) ListTableMetadata(ctx context.Context, params *athena.ListTableMetadataInput, optFns ...func(*athena.Options)) (*athena.ListTableMetadataOutput, error) {
+	f.deadlines["tables"], _ = ctx.Deadline()
+	return f.FakeAthena.ListTableMetadata(ctx, params, optFns...)
+}
+
+func (f *deadlineAthena) ListPreparedStatements(ctx context.Context, params *athena.ListPreparedStatementsInput, optFns ...func(*athena.Options)) (*athena.ListPreparedStatementsOutput, error) {
+	f.deadlines["prepared"], _ = ctx.Deadline()
+	return f.FakeAthena.ListPreparedStatements(ctx, params, optFns...)
+}
+
+func TestCompletionTimesOut(t *testing.T) {
+	s, fake := newTestSession(t)
+	client := &deadlineAthena{FakeAthena: fake, deadlines: map[string]time.Time{}}
+	s.Client = client
+
+	start := time.Now()
+	s.Complete("select * from ord", len("select * from ord"))
+	s.Complete(".exec ", len(".exec "))
+	for _, call := range []string{"tables", "prepared"} {
+		deadline, ok := client.deadlines[call]
+		if !ok {
+			t.Errorf("%s were not listed", call)
+			continue
+		}
+		if deadline.IsZero() || deadline.After(time.Now().Add(completionTimeout)) || deadline.Before(start) {
+			t.Errorf("%s listed with deadline %v, want within %s", call, deadline, completionTimeout)
+		}
+	}
+}
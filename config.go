@@ -10,8 +10,17 @@ import (
 const VERSION string = "${version}"
 
 type SavedCfg struct {
-	WorkGroup string `json:"workgroup"`
-	Database  string `json:"database"`
+	WorkGroup     string   `json:"workgroup"`
+	Catalog       string   `json:"catalog,omitempty"`
+	Database      string   `json:"database"`
+	ReadOnlyAllow []string `json:"readonly_allow,omitempty"`
+	// scan limits are sizes such as 50GB
+	ScanLimit        string `json:"scan_limit,omitempty"`
+	SessionScanLimit string `json:"session_scan_limit,omitempty"`
+	// PricePerTB is used to estimate the cost of queries
+	PricePerTB float64 `json:"price_per_tb,omitempty"`
+	// MaxPollInterval is the longest wait between checks on a query, e.g. 10s
+	MaxPollInterval string `json:"max_poll_interval,omitempty"`
 }
 
 func ReadConfig() (SavedCfg, error) {
@@ -38,7 +47,7 @@ func ReadConfig() (SavedCfg, error) {
 	return cfg, nil
 }
 
-func WriteConfig(database string, workGroup string) error {
+func WriteConfig(catalog string, database string, workGroup string) error {
 	userHome, userHomeErr := os.UserHomeDir()
 	if userHomeErr != nil {
 		fmt.Println("Error: could not get home directory", userHomeErr)
@@ -49,7 +58,12 @@ func WriteConfig(database string, workGroup string) error {
 		fmt.Println("Error: could not create .athena-query directory", mkdirError)
 		return mkdirError
 	}
-	var cfg SavedCfg
+	// start from the saved config so settings only edited by hand are kept
+	cfg, readErr := ReadConfig()
+	if readErr != nil {
+		return readErr
+	}
+	cfg.Catalog = catalog
 	cfg.Database = database
 	cfg.WorkGroup = workGroup
 	data, marshalError := json.MarshalIndent(cfg, "", " ")
@@ -62,3 +76,21 @@ func WriteConfig(database string, workGroup string) error {
 	}
 	return nil
 }
+
+// HistoryFile returns the path of the file the shell history is kept in
+func HistoryFile() (string, error) {
+	userHome, userHomeErr := os.UserHomeDir()
+	if userHomeErr != nil {
+		return "", userHomeErr
+	}
+	return userHome + "/.athena-query/history", nil
+}
+
+// QueryLogFile returns the path of the file every query run is logged to
+func QueryLogFile() (string, error) {
+	userHome, userHomeErr := os.UserHomeDir()
+	if userHomeErr != nil {
+		return "", userHomeErr
+	}
+	return userHome + "/.athena-query/queries.jsonl", nil
+}
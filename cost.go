@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultPricePerTB is the Athena price in US dollars for each TB scanned
+const DefaultPricePerTB float64 = 5.0
+
+// Athena bills scanned bytes rounded up to the next MB with a 10MB minimum
+const (
+	billingUnit     int64 = 1 << 20
+	minBilledBytes  int64 = 10 << 20
+	bytesPerTB      int64 = 1 << 40
+	costQueryLength int   = 60
+)
+
+// queryCost is the estimated cost of one query
+type queryCost struct {
+	id      string
+	query   string
+	scanned int64
+	cost    float64
+}
+
+// BilledBytes returns the number of bytes Athena charges for when a query
+// scans the given amount
+func BilledBytes(scanned int64) int64 {
+	billed := (scanned + billingUnit - 1) / billingUnit * billingUnit
+	if billed < minBilledBytes {
+		return minBilledBytes
+	}
+	return billed
+}
+
+// EstimateCost returns the cost in dollars of scanning the given amount
+func EstimateCost(scanned int64, pricePerTB float64) float64 {
+	return float64(BilledBytes(scanned)) / float64(bytesPerTB) * pricePerTB
+}
+
+// FormatCost shows small costs with enough places that they are not zero
+func FormatCost(cost float64) string {
+	if cost != 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.6f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+// recordCost adds a query to the cost totals and returns its cost.  Only
+// queries which ran are charged for, a query the tool stopped was last seen
+// running, while failed queries, queries which never left the queue and DDL
+// are free
+func (s *Session) recordCost(id string, query string, queryRes QuerySummary, scanned int64) float64 {
+	switch queryRes.State {
+	case "SUCCEEDED", "CANCELLED", "RUNNING":
+	default:
+		return 0
+	}
+	if queryRes.StmtType == "DDL" {
+		return 0
+	}
+	cost := EstimateCost(scanned, s.PricePerTB)
+	s.costs = append(s.costs, queryCost{
+		id:      id,
+		query:   strings.Join(strings.Fields(query), " "),
+		scanned: scanned,
+		cost:    cost,
+	})
+	return cost
+}
+
+// SetPrice changes the price per TB used for estimates of later queries
+func (s *Session) SetPrice(price string) error {
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil || value < 0 {
+		return fmt.Errorf("'%s' is not a price, use the cost in dollars of scanning 1 TB, e.g. 5", price)
+	}
+	s.PricePerTB = value
+	return nil
+}
+
+// ShowCosts prints the cost of every query run in the session
+func (s *Session) ShowCosts() {
+	if len(s.costs) == 0 {
+		fmt.Println("No queries have been run.")
+		return
+	}
+	for i, c := range s.costs {
+		query := abbreviate(c.query, costQueryLength)
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", i+1, c.id, FormatBytes(c.scanned), FormatCost(c.cost), query)
+	}
+	s.printCostTotals()
+}
+
+// printCostTotals prints the totals for the session, if any queries have run
+func (s *Session) printCostTotals() {
+	if len(s.costs) == 0 {
+		return
+	}
+	var scanned int64
+	var cost float64
+	for _, c := range s.costs {
+		scanned += c.scanned
+		cost += c.cost
+	}
+	fmt.Printf("Session total: %d queries, %s scanned, estimated cost %s (price is %s per TB)\n",
+		len(s.costs), FormatBytes(scanned), FormatCost(cost), FormatCost(s.PricePerTB))
+}
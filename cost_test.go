@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestBilledBytes(t *testing.T) {
+	tests := []struct {
+		scanned int64
+		want    int64
+	}{
+		{0, 10 << 20},
+		{1, 10 << 20},
+		{10 << 20, 10 << 20},
+		{10<<20 + 1, 11 << 20},
+		{1 << 40, 1 << 40},
+	}
+	for _, tt := range tests {
+		if got := BilledBytes(tt.scanned); got != tt.want {
+			t.Errorf("BilledBytes(%d) = %d, want %d", tt.scanned, got, tt.want)
+		}
+	}
+}
+
+func TestRecordCost(t *testing.T) {
+	tests := []struct {
+		state    string
+		stmtType string
+		charged  bool
+	}{
+		{"SUCCEEDED", "DML", true},
+		{"CANCELLED", "DML", true},
+		{"RUNNING", "DML", true},
+		{"FAILED", "DML", false},
+		{"QUEUED", "DML", false},
+		{"", "", false},
+		{"SUCCEEDED", "DDL", false},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		cost := s.recordCost("id", "select 1", QuerySummary{State: tt.state, StmtType: tt.stmtType}, 1<<40)
+		if charged := cost > 0; charged != tt.charged {
+			t.Errorf("%s %s query charged %v, want %v", tt.state, tt.stmtType, charged, tt.charged)
+		}
+		if charged := len(s.costs) > 0; charged != tt.charged {
+			t.Errorf("%s %s query recorded %v, want %v", tt.state, tt.stmtType, charged, tt.charged)
+		}
+		if tt.charged && math.Abs(cost-DefaultPricePerTB) > 1e-9 {
+			t.Errorf("1TB cost %v, want %v", cost, DefaultPricePerTB)
+		}
+	}
+}
+
+func TestRunQueryWithoutStats(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		s, fake := newTestSession(t)
+		s.Client = &noStatsAthena{fake}
+		s.ShowStats, s.FullStats = true, full
+		out := captureOutput(t, func() {
+			s.SendLine("select 1;")
+		})
+		if !strings.Contains(out, noStatsLines[full]) {
+			t.Errorf("printed %q, want %q", out, noStatsLines[full])
+		}
+	}
+}
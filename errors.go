@@ -24,3 +24,16 @@ func PrettyPrintAwsError(err error) {
 	// catch all, just print the error message
 	fmt.Printf("Error: %s\n", err)
 }
+
+// IsThrottlingError reports if a request was rejected because too many
+// requests have been made, it is safe to retry after waiting
+func IsThrottlingError(err error) bool {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		switch ae.ErrorCode() {
+		case "ThrottlingException", "TooManyRequestsException":
+			return true
+		}
+	}
+	return false
+}
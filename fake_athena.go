@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// FakeQuery scripts how FakeAthena responds to a single query
+type FakeQuery struct {
+	// States are returned by successive GetQueryExecution calls, the last one repeats
+	States            []types.QueryExecutionState
+	StateChangeReason string
+	StatementType     types.StatementType
+	Stats             *types.QueryExecutionStatistics
+	// Pages are returned one at a time by GetQueryResults
+	Pages []types.ResultSet
+
+	StartErr   error
+	MonitorErr error
+	ResultsErr error
+	StopErr    error
+}
+
+// FakeAthena is an in-memory implementation of AthenaAPI which can be scripted
+// with query states, result pages and errors so the tool can run offline
+type FakeAthena struct {
+	// Queries maps a query string (trimmed) to the response it should get
+	Queries map[string]*FakeQuery
+	// Default is used for queries not found in Queries, if nil the query is echoed back
+	Default *FakeQuery
+	// WorkGroups maps a work group name to its output location
+	WorkGroups map[string]string
+	// Tables maps a database name to the metadata of its tables
+	Tables map[string][]types.TableMetadata
+	// Started records every StartQueryExecution request in order
+	Started []athena.StartQueryExecutionInput
+	// S3 if set receives a result CSV for each query, like Athena's output location
+	S3 *FakeS3
+
+	mu         sync.Mutex
+	nextId     int
+	executions map[string]*fakeExecution
+	prepared   map[string]types.PreparedStatement
+}
+
+type fakeExecution struct {
+	query          *FakeQuery
+	input          athena.StartQueryExecutionInput
+	polls          int
+	stopped        bool
+	outputLocation string
+	submitted      time.Time
+}
+
+var _ AthenaAPI = (*FakeAthena)(nil)
+
+func NewFakeAthena() *FakeAthena {
+	return &FakeAthena{
+		Queries:    map[string]*FakeQuery{},
+		WorkGroups: map[string]string{},
+		Tables:     map[string][]types.TableMetadata{},
+		executions: map[string]*fakeExecution{},
+		prepared:   map[string]types.PreparedStatement{},
+	}
+}
+
+// AddQuery registers the scripted response for a query string
+func (f *FakeAthena) AddQuery(sql string, q *FakeQuery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Queries[strings.TrimSpace(sql)] = q
+}
+
+// FakeResultSet builds a result page in the same shape Athena returns, the
+// first row holds the column names
+func FakeResultSet(columns []string, rows ...[]string) types.ResultSet {
+	var rs types.ResultSet
+	rs.ResultSetMetadata = &types.ResultSetMetadata{}
+	var header types.Row
+	for _, col := range columns {
+		rs.ResultSetMetadata.ColumnInfo = append(rs.ResultSetMetadata.ColumnInfo, types.ColumnInfo{
+			Name: aws.String(col),
+			Type: aws.String("varchar"),
+		})
+		header.Data = append(header.Data, types.Datum{VarCharValue: aws.String(col)})
+	}
+	rs.Rows = append(rs.Rows, header)
+	for _, row := range rows {
+		var r types.Row
+		for _, val := range row {
+			r.Data = append(r.Data, types.Datum{VarCharValue: aws.String(val)})
+		}
+		rs.Rows = append(rs.Rows, r)
+	}
+	return rs
+}
+
+func (f *FakeAthena) StartQueryExecution(ctx context.Context, params *athena.StartQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.Started = append(f.Started, *params)
+	sql := strings.TrimSpace(aws.ToString(params.QueryString))
+	q, exists := f.Queries[sql]
+	if !exists {
+		q = f.Default
+	}
+	if q == nil {
+		q = &FakeQuery{
+			StatementType: types.StatementTypeDml,
+			Pages:         []types.ResultSet{FakeResultSet([]string{"query"}, []string{sql})},
+		}
+	}
+	if q.StartErr != nil {
+		return nil, q.StartErr
+	}
+
+	f.nextId++
+	id := fmt.Sprintf("fake-%08d", f.nextId)
+	exec := &fakeExecution{query: q, input: *params, submitted: time.Now()}
+	if location := f.WorkGroups[aws.ToString(params.WorkGroup)]; location != "" {
+		exec.outputLocation = strings.TrimSuffix(location, "/") + "/" + id + ".csv"
+		if f.S3 != nil {
+			if err := f.S3.PutObject(exec.outputLocation, fakeResultCsv(q.Pages)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	f.executions[id] = exec
+	return &athena.StartQueryExecutionOutput{QueryExecutionId: aws.String(id)}, nil
+}
+
+func (f *FakeAthena) GetQueryExecution(ctx context.Context, params *athena.GetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exec, err := f.getExecution(params.QueryExecutionId)
+	if err != nil {
+		return nil, err
+	}
+	if exec.query.MonitorErr != nil {
+		return nil, exec.query.MonitorErr
+	}
+	qe := exec.describe(aws.ToString(params.QueryExecutionId))
+	exec.polls++
+	return &athena.GetQueryExecutionOutput{QueryExecution: qe}, nil
+}
+
+func (f *FakeAthena) ListQueryExecutions(ctx context.Context, params *athena.ListQueryExecutionsInput, optFns ...func(*athena.Options)) (*athena.ListQueryExecutionsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// newest first, like Athena
+	var ids []string
+	for id, exec := range f.executions {
+		if aws.ToString(exec.input.WorkGroup) == aws.ToString(params.WorkGroup) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	start := 0
+	if params.NextToken != nil {
+		start, _ = strconv.Atoi(aws.ToString(params.NextToken))
+	}
+	pageSize := 50
+	if params.MaxResults != nil {
+		pageSize = int(aws.ToInt32(params.MaxResults))
+	}
+	if start > len(ids) {
+		start = len(ids)
+	}
+	end := start + pageSize
+	out := &athena.ListQueryExecutionsOutput{}
+	if end < len(ids) {
+		out.NextToken = aws.String(strconv.Itoa(end))
+	} else {
+		end = len(ids)
+	}
+	out.QueryExecutionIds = ids[start:end]
+	return out, nil
+}
+
+func (f *FakeAthena) BatchGetQueryExecution(ctx context.Context, params *athena.BatchGetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.BatchGetQueryExecutionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := &athena.BatchGetQueryExecutionOutput{}
+	for _, id := range params.QueryExecutionIds {
+		exec, exists := f.executions[id]
+		if !exists {
+			out.UnprocessedQueryExecutionIds = append(out.UnprocessedQueryExecutionIds, types.UnprocessedQueryExecutionId{
+				QueryExecutionId: aws.String(id),
+				ErrorCode:        aws.String("InvalidRequestException"),
+				ErrorMessage:     aws.String(fmt.Sprintf("QueryExecution %s was not found", id)),
+			})
+			continue
+		}
+		out.QueryExecutions = append(out.QueryExecutions, *exec.describe(id))
+	}
+	return out, nil
+}
+
+func (f *FakeAthena) GetQueryResults(ctx context.Context, params *athena.GetQueryResultsInput, optFns ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exec, err := f.getExecution(params.QueryExecutionId)
+	if err != nil {
+		return nil, err
+	}
+	q := exec.query
+	if q.ResultsErr != nil {
+		return nil, q.ResultsErr
+	}
+	if len(q.Pages) == 0 {
+		return &athena.GetQueryResultsOutput{
+			ResultSet: &types.ResultSet{ResultSetMetadata: &types.ResultSetMetadata{}},
+		}, nil
+	}
+
+	page := 0
+	if params.NextToken != nil {
+		page, err = strconv.Atoi(*params.NextToken)
+		if err != nil || page < 0 || page >= len(q.Pages) {
+			return nil, &types.InvalidRequestException{Message: aws.String("invalid next token")}
+		}
+	}
+	rs := q.Pages[page]
+	resp := &athena.GetQueryResultsOutput{ResultSet: &rs}
+	if page+1 < len(q.Pages) {
+		resp.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return resp, nil
+}
+
+func (f *FakeAthena) GetWorkGroup(ctx context.Context, params *athena.GetWorkGroupInput, optFns ...func(*athena.Options)) (*athena.GetWorkGroupOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	outputLocation, exists := f.WorkGroups[aws.ToString(params.WorkGroup)]
+	if !exists {
+		return nil, &types.InvalidRequestException{
+			Message: aws.String(fmt.Sprintf("WorkGroup %s is not found.", aws.ToString(params.WorkGroup))),
+		}
+	}
+	var resultCfg types.ResultConfiguration
+	if outputLocation != "" {
+		resultCfg.OutputLocation = aws.String(outputLocation)
+	}
+	return &athena.GetWorkGroupOutput{
+		WorkGroup: &types.WorkGroup{
+			Name:  params.WorkGroup,
+			State: types.WorkGroupStateEnabled,
+			Configuration: &types.WorkGroupConfiguration{
+				ResultConfiguration: &resultCfg,
+			},
+		},
+	}, nil
+}
+
+func (f *FakeAthena) StopQueryExecution(ctx context.Context, params *athena.StopQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StopQueryExecutionOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	exec, err := f.getExecution(params.QueryExecutionId)
+	if err != nil {
+		return nil, err
+	}
+	if exec.query.StopErr != nil {
+		return nil, exec.query.StopErr
+	}
+	exec.stopped = true
+	return &athena.StopQueryExecutionOutput{}, nil
+}
+
+func (f *FakeAthena) CreatePreparedStatement(ctx context.Context, params *athena.CreatePreparedStatementInput, optFns ...func(*athena.Options)) (*athena.CreatePreparedStatementOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := aws.ToString(params.WorkGroup) + "/" + aws.ToString(params.StatementName)
+	if _, exists := f.prepared[key]; exists {
+		return nil, &types.InvalidRequestException{
+			Message: aws.String(fmt.Sprintf("Prepared statement %s already exists", aws.ToString(params.StatementName))),
+		}
+	}
+	now := time.Now()
+	f.prepared[key] = types.PreparedStatement{
+		StatementName:    params.StatementName,
+		QueryStatement:   params.QueryStatement,
+		WorkGroupName:    params.WorkGroup,
+		Description:      params.Description,
+		LastModifiedTime: &now,
+	}
+	return &athena.CreatePreparedStatementOutput{}, nil
+}
+
+func (f *FakeAthena) GetPreparedStatement(ctx context.Context, params *athena.GetPreparedStatementInput, optFns ...func(*athena.Options)) (*athena.GetPreparedStatementOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stmt, err := f.getPrepared(params.WorkGroup, params.StatementName)
+	if err != nil {
+		return nil, err
+	}
+	return &athena.GetPreparedStatementOutput{PreparedStatement: &stmt}, nil
+}
+
+func (f *FakeAthena) ListPreparedStatements(ctx context.Context, params *athena.ListPreparedStatementsInput, optFns ...func(*athena.Options)) (*athena.ListPreparedStatementsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var summaries []types.PreparedStatementSummary
+	for _, stmt := range f.prepared {
+		if aws.ToString(stmt.WorkGroupName) == aws.ToString(params.WorkGroup) {
+			summaries = append(summaries, types.PreparedStatementSummary{
+				StatementName:    stmt.StatementName,
+				LastModifiedTime: stmt.LastModifiedTime,
+			})
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return aws.ToString(summaries[i].StatementName) < aws.ToString(summaries[j].StatementName)
+	})
+	return &athena.ListPreparedStatementsOutput{PreparedStatements: summaries}, nil
+}
+
+func (f *FakeAthena) DeletePreparedStatement(ctx context.Context, params *athena.DeletePreparedStatementInput, optFns ...func(*athena.Options)) (*athena.DeletePreparedStatementOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.getPrepared(params.WorkGroup, params.StatementName); err != nil {
+		return nil, err
+	}
+	delete(f.prepared, aws.ToString(params.WorkGroup)+"/"+aws.ToString(params.StatementName))
+	return &athena.DeletePreparedStatementOutput{}, nil
+}
+
+func (f *FakeAthena) ListTableMetadata(ctx context.Context, params *athena.ListTableMetadataInput, optFns ...func(*athena.Options)) (*athena.ListTableMetadataOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tables, exists := f.Tables[aws.ToString(params.DatabaseName)]
+	if !exists {
+		return nil, &types.MetadataException{
+			Message: aws.String(fmt.Sprintf("Database %s not found.", aws.ToString(params.DatabaseName))),
+		}
+	}
+	return &athena.ListTableMetadataOutput{TableMetadataList: tables}, nil
+}
+
+func (f *FakeAthena) getPrepared(workGroup *string, name *string) (types.PreparedStatement, error) {
+	stmt, exists := f.prepared[aws.ToString(workGroup)+"/"+aws.ToString(name)]
+	if !exists {
+		return stmt, &types.ResourceNotFoundException{
+			Message: aws.String(fmt.Sprintf("Prepared statement %s was not found", aws.ToString(name))),
+		}
+	}
+	return stmt, nil
+}
+
+// fakeResultCsv renders result pages the way Athena writes them to S3
+func fakeResultCsv(pages []types.ResultSet) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, page := range pages {
+		for _, row := range page.Rows {
+			var record []string
+			for _, col := range row.Data {
+				record = append(record, aws.ToString(col.VarCharValue))
+			}
+			w.Write(record)
+		}
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+func (f *FakeAthena) getExecution(id *string) (*fakeExecution, error) {
+	exec, exists := f.executions[aws.ToString(id)]
+	if !exists {
+		return nil, &types.InvalidRequestException{
+			Message: aws.String(fmt.Sprintf("QueryExecution %s was not found", aws.ToString(id))),
+		}
+	}
+	return exec, nil
+}
+
+// describe returns the execution as Athena would report it at this point in
+// its scripted states
+func (exec *fakeExecution) describe(id string) *types.QueryExecution {
+	q := exec.query
+	state := types.QueryExecutionStateSucceeded
+	if len(q.States) > 0 {
+		if exec.polls < len(q.States) {
+			state = q.States[exec.polls]
+		} else {
+			state = q.States[len(q.States)-1]
+		}
+	}
+	if exec.stopped {
+		state = types.QueryExecutionStateCancelled
+	}
+
+	stats := q.Stats
+	if stats == nil {
+		stats = &types.QueryExecutionStatistics{
+			DataScannedInBytes:          aws.Int64(0),
+			EngineExecutionTimeInMillis: aws.Int64(0),
+		}
+	}
+	status := &types.QueryExecutionStatus{State: state, SubmissionDateTime: aws.Time(exec.submitted)}
+	if q.StateChangeReason != "" {
+		status.StateChangeReason = aws.String(q.StateChangeReason)
+	}
+	return &types.QueryExecution{
+		QueryExecutionId:      aws.String(id),
+		Query:                 exec.input.QueryString,
+		QueryExecutionContext: exec.input.QueryExecutionContext,
+		WorkGroup:             exec.input.WorkGroup,
+		ExecutionParameters:   exec.input.ExecutionParameters,
+		EngineVersion: &types.EngineVersion{
+			SelectedEngineVersion:  aws.String("AUTO"),
+			EffectiveEngineVersion: aws.String("Athena engine version 3"),
+		},
+		StatementType:       q.StatementType,
+		Statistics:          stats,
+		Status:              status,
+		ResultConfiguration: &types.ResultConfiguration{OutputLocation: aws.String(exec.outputLocation)},
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestFakeAthenaThroughSession(t *testing.T) {
+	// only the first page of a result starts with the column names
+	secondPage := FakeResultSet([]string{"region", "total"}, []string{"south", "20"})
+	secondPage.Rows = secondPage.Rows[1:]
+
+	tests := []struct {
+		name  string
+		query *FakeQuery
+		sql   string
+		want  []string
+	}{
+		{
+			name: "scripted pages",
+			query: &FakeQuery{
+				States: []types.QueryExecutionState{
+					types.QueryExecutionStateQueued,
+					types.QueryExecutionStateRunning,
+					types.QueryExecutionStateSucceeded,
+				},
+				StatementType: types.StatementTypeDml,
+				Pages: []types.ResultSet{
+					FakeResultSet([]string{"region", "total"}, []string{"north", "10"}),
+					secondPage,
+				},
+			},
+			sql:  "select region, total from sales",
+			want: []string{"region,total\n", "north,10\n", "south,20\n"},
+		},
+		{
+			name: "echoed",
+			sql:  "select 42",
+			want: []string{"query\n", "select 42\n"},
+		},
+		{
+			name: "failed",
+			query: &FakeQuery{
+				States:            []types.QueryExecutionState{types.QueryExecutionStateFailed},
+				StateChangeReason: "TABLE_NOT_FOUND: sales",
+			},
+			sql:  "select * from sales",
+			want: []string{"TABLE_NOT_FOUND: sales"},
+		},
+		{
+			name:  "rejected",
+			query: &FakeQuery{StartErr: errors.New("access denied")},
+			sql:   "select * from secret",
+			want:  []string{"access denied"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestSession(t)
+			s.OutputMode = "csv"
+			if tt.query != nil {
+				fake.AddQuery(tt.sql, tt.query)
+			}
+			out := captureOutput(t, func() {
+				s.SendLine(tt.sql + ";")
+			})
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("printed %q, want %q in it", out, want)
+				}
+			}
+			if len(fake.Started) != 1 || aws.ToString(fake.Started[0].QueryString) != tt.sql {
+				t.Errorf("started %v, want just %q", fake.Started, tt.sql)
+			}
+		})
+	}
+}
+
+func TestFakeAthenaStopQuery(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFakeAthena()
+	fake.AddQuery("select slow", &FakeQuery{
+		States: []types.QueryExecutionState{types.QueryExecutionStateRunning},
+	})
+	fake.AddQuery("select stuck", &FakeQuery{
+		States:  []types.QueryExecutionState{types.QueryExecutionStateRunning},
+		StopErr: errors.New("not allowed"),
+	})
+
+	tests := []struct {
+		sql       string
+		wantErr   bool
+		wantState types.QueryExecutionState
+	}{
+		{"select slow", false, types.QueryExecutionStateCancelled},
+		{"select stuck", true, types.QueryExecutionStateRunning},
+	}
+	for _, tt := range tests {
+		started, err := fake.StartQueryExecution(ctx, &athena.StartQueryExecutionInput{QueryString: aws.String(tt.sql)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fake.StopQueryExecution(ctx, &athena.StopQueryExecutionInput{QueryExecutionId: started.QueryExecutionId})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("stopping %q returned %v", tt.sql, err)
+		}
+		got, err := fake.GetQueryExecution(ctx, &athena.GetQueryExecutionInput{QueryExecutionId: started.QueryExecutionId})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state := got.QueryExecution.Status.State; state != tt.wantState {
+			t.Errorf("%q is %s after stopping, want %s", tt.sql, state, tt.wantState)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// FakeS3 is an in-memory implementation of S3API
+type FakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+var _ S3API = (*FakeS3)(nil)
+
+func NewFakeS3() *FakeS3 {
+	return &FakeS3{
+		objects: map[string][]byte{},
+	}
+}
+
+// PutObject stores an object at an s3://bucket/key location
+func (f *FakeS3) PutObject(uri string, data []byte) error {
+	bucket, key, err := ParseS3Uri(uri)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[bucket+"/"+key] = data
+	return nil
+}
+
+func (f *FakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, exists := f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !exists {
+		return nil, &types.NoSuchKey{
+			Message: aws.String(fmt.Sprintf("The specified key does not exist: %s", aws.ToString(params.Key))),
+		}
+	}
+	return &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+	}, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+
+	"github.com/peterh/liner"
+)
+
+// ReadHistory loads the saved shell history into the line editor, it is not
+// an error for there to be no history yet
+func ReadHistory(line *liner.State) error {
+	historyFile, err := HistoryFile()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(historyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	_, err = line.ReadHistory(f)
+	return err
+}
+
+// WriteHistory saves the shell history so it is available next time
+func WriteHistory(line *liner.State) error {
+	historyFile, err := HistoryFile()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(historyFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = line.WriteHistory(f)
+	return err
+}
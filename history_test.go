@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peterh/liner"
+)
+
+func TestHistoryKeptBetweenSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, ".athena-query"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	entries := []string{"select 1;", "select region, sum(total) from sales group by region;"}
+
+	// there is nothing to read the first time
+	line := liner.NewLiner()
+	if err := ReadHistory(line); err != nil {
+		t.Fatalf("reading missing history: %v", err)
+	}
+	for _, entry := range entries {
+		line.AppendHistory(entry)
+	}
+	if err := WriteHistory(line); err != nil {
+		t.Fatal(err)
+	}
+	line.Close()
+
+	line = liner.NewLiner()
+	defer line.Close()
+	if err := ReadHistory(line); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if _, err := line.WriteHistory(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := entries[0] + "\n" + entries[1] + "\n"
+	if got.String() != want {
+		t.Errorf("history reloaded as %q, want %q", got.String(), want)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// jobQueryLength is how much of the SQL is shown in the job table
+const jobQueryLength int = 50
+
+// Job is a query running in the background while the shell carries on
+type Job struct {
+	Num     int
+	Id      string
+	Query   string
+	Started time.Time
+
+	mu       sync.Mutex
+	state    string
+	scanned  int64
+	finished time.Time
+	result   QuerySummary
+	err      error
+	done     chan struct{}
+	reported bool // the user has been told the job finished
+}
+
+// Done reports if the job has finished
+func (j *Job) Done() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Status returns the state of the job, how long it ran for and how much data
+// it has scanned
+func (j *Job) Status() (string, time.Duration, int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	end := j.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return j.state, end.Sub(j.Started), j.scanned
+}
+
+// StartJob submits a query and monitors it in the background
+func (s *Session) StartJob(query string, params []string) {
+	budgetErr := s.checkSessionBudget()
+	if budgetErr != nil {
+		fmt.Printf("Error: %s\n", budgetErr)
+		return
+	}
+
+	// Ctrl-C stops it waiting for Athena to accept the query
+	startCtx := s.startQuery()
+	id, queryErr := StartQueryWithRetry(query, s.WorkGroup, s.Catalog, s.Database, params, s.MaxPollInterval, s.Client, startCtx)
+	s.finishQuery()
+	if queryErr != nil {
+		s.LogQuery(NewQueryLogEntry(s.WorkGroup, s.Catalog, s.Database, "", query, QuerySummary{}, queryErr))
+		PrettyPrintAwsError(queryErr)
+		return
+	}
+	job := &Job{
+		Num:     len(s.jobs) + 1,
+		Id:      id,
+		Query:   strings.Join(strings.Fields(query), " "),
+		Started: time.Now(),
+		state:   "QUEUED",
+		done:    make(chan struct{}),
+	}
+	s.jobs = append(s.jobs, job)
+	fmt.Printf("[%d] %s\n", job.Num, id)
+
+	// settings are copied so they can change while the job runs
+	client, maxPoll, timeout, scanLimit := s.Client, s.MaxPollInterval, s.Timeout, s.ScanLimit
+	workGroup, catalog, database := s.WorkGroup, s.Catalog, s.Database
+	ctx, cancel := withTimeout(s.Ctx, timeout)
+	go func() {
+		defer close(job.done)
+		defer cancel()
+		res, err := MonitorQuery(id, maxPoll, func(exec *types.QueryExecution) error {
+			job.mu.Lock()
+			defer job.mu.Unlock()
+			job.state = string(exec.Status.State)
+			if exec.Statistics != nil {
+				job.scanned = aws.ToInt64(exec.Statistics.DataScannedInBytes)
+			}
+			if scanLimit > 0 && job.scanned > scanLimit {
+				return &ScanLimitError{Scanned: job.scanned, Limit: scanLimit}
+			}
+			return nil
+		}, client, ctx)
+		s.LogQuery(NewQueryLogEntry(workGroup, catalog, database, id, query, res, err))
+
+		var limitErr *ScanLimitError
+		if errors.As(err, &limitErr) || errors.Is(err, context.DeadlineExceeded) {
+			// the query is still running in Athena so it needs stopping
+			stopErr := StopQuery(id, client, s.Ctx)
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("query timed out after %s", timeout)
+			}
+			if stopErr != nil {
+				err = fmt.Errorf("%v, and could not be stopped: %v", err, stopErr)
+			} else {
+				res.State = string(types.QueryExecutionStateCancelled)
+				err = fmt.Errorf("%v, query stopped", err)
+			}
+		}
+
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		job.result = res
+		job.err = err
+		job.finished = time.Now()
+		if res.State != "" {
+			job.state = res.State
+		}
+		if res.Stats != nil {
+			job.scanned = aws.ToInt64(res.Stats.DataScannedInBytes)
+		}
+	}()
+}
+
+// finishJob adds a finished job to the session totals and tells the user
+// about it, it only does anything the first time it is called for a job
+func (s *Session) finishJob(job *Job) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.reported {
+		return
+	}
+	job.reported = true
+	s.scannedTotal += job.scanned
+	s.recordCost(job.Id, job.Query, job.result, job.scanned)
+	if job.err != nil {
+		fmt.Printf("[%d] %s %s: %s\n", job.Num, job.state, job.Id, job.err)
+	} else {
+		fmt.Printf("[%d] %s %s\n", job.Num, job.state, job.Id)
+	}
+}
+
+// ReportJobs tells the user about any background jobs which have finished
+// since it was last called
+func (s *Session) ReportJobs() {
+	for _, job := range s.jobs {
+		if job.Done() {
+			s.finishJob(job)
+		}
+	}
+}
+
+// ReportRunningJobs tells the user about finished jobs and warns about any
+// still running, which carry on in Athena after the shell exits
+func (s *Session) ReportRunningJobs() {
+	s.ReportJobs()
+	for _, job := range s.jobs {
+		if !job.Done() {
+			state, _, _ := job.Status()
+			fmt.Printf("[%d] %s %s is still running in Athena, use '.attach %s' to follow it\n", job.Num, state, job.Id, job.Id)
+		}
+	}
+}
+
+// findJob returns the job with the number given by the user
+func (s *Session) findJob(num string) (*Job, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(num, "%"))
+	if err != nil || n < 1 || n > len(s.jobs) {
+		return nil, fmt.Errorf("there is no job '%s', use .jobs to list them", num)
+	}
+	return s.jobs[n-1], nil
+}
+
+// ListJobs prints the table of background jobs
+func (s *Session) ListJobs() {
+	if len(s.jobs) == 0 {
+		fmt.Println("No background jobs.")
+		return
+	}
+	for _, job := range s.jobs {
+		state, elapsed, scanned := job.Status()
+		query := abbreviate(job.Query, jobQueryLength)
+		fmt.Printf("[%d]\t%s\t%s\t%s\t%s\t%s\n", job.Num, job.Id, state, elapsed.Truncate(100*time.Millisecond), FormatBytes(scanned), query)
+	}
+}
+
+// WaitJob blocks until a job has finished, Ctrl-C stops waiting but leaves
+// the job running
+func (s *Session) WaitJob(num string) error {
+	job, err := s.findJob(num)
+	if err != nil {
+		return err
+	}
+	waitCtx := s.startQuery()
+	defer s.finishQuery()
+	select {
+	case <-job.done:
+		s.finishJob(job)
+		return nil
+	case <-waitCtx.Done():
+		if errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("job %d is still running after waiting %s", job.Num, s.Timeout)
+		}
+		fmt.Printf("Stopped waiting, job %d is still running\n", job.Num)
+		return nil
+	}
+}
+
+// JobResult outputs the results of a finished job using the current output
+// settings
+func (s *Session) JobResult(num string) error {
+	job, err := s.findJob(num)
+	if err != nil {
+		return err
+	}
+	if !job.Done() {
+		state, _, _ := job.Status()
+		return fmt.Errorf("job %d is still %s, use '.wait %d' to wait for it", job.Num, state, job.Num)
+	}
+	s.finishJob(job)
+	if job.err != nil || !job.result.Successful {
+		return fmt.Errorf("job %d did not succeed so it has no results", job.Num)
+	}
+
+	resultCtx := s.startQuery()
+	defer s.finishQuery()
+	resultErr := s.OutputQueryResults(job.Id, job.result, resultCtx)
+	if resultCtx.Err() != nil {
+		fmt.Println("Fetching results cancelled")
+		return nil
+	}
+	return resultErr
+}
+
+// KillJob stops a background job in Athena
+func (s *Session) KillJob(num string) error {
+	job, err := s.findJob(num)
+	if err != nil {
+		return err
+	}
+	if job.Done() {
+		state, _, _ := job.Status()
+		return fmt.Errorf("job %d has already finished, it is %s", job.Num, state)
+	}
+	return StopQuery(job.Id, s.Client, s.Ctx)
+}
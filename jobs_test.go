@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestJobReportsStopError(t *testing.T) {
+	s, fake := newTestSession(t)
+	fake.AddQuery("select big", &FakeQuery{
+		States:  []types.QueryExecutionState{types.QueryExecutionStateRunning},
+		Stats:   &types.QueryExecutionStatistics{DataScannedInBytes: aws.Int64(2 << 30)},
+		StopErr: errors.New("access denied"),
+	})
+	s.ScanLimit = 1 << 30
+
+	s.SendLine("select big &")
+	if len(s.jobs) != 1 {
+		t.Fatalf("%d jobs started, want 1", len(s.jobs))
+	}
+	job := s.jobs[0]
+	<-job.done
+	if job.err == nil || !strings.Contains(job.err.Error(), "could not be stopped: access denied") {
+		t.Errorf("job error is %v", job.err)
+	}
+	if job.state == string(types.QueryExecutionStateCancelled) {
+		t.Error("job which could not be stopped is shown as cancelled")
+	}
+}
+
+func TestBackgroundWithoutSemicolon(t *testing.T) {
+	tests := []struct {
+		line       string
+		background bool
+	}{
+		{"select 1 &", true},
+		{"select 1 & -- later", true},
+		{"select 1 & /* later */", true},
+		{"select '&'", false},
+		{"select 1 -- &", false},
+		{"select 1 /* & */", false},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		captureOutput(t, func() {
+			s.SendLine(tt.line)
+		})
+		if !tt.background {
+			if len(s.jobs) != 0 || !s.Pending() {
+				t.Errorf("%q started %d jobs, want it to wait for a ';'", tt.line, len(s.jobs))
+			}
+			continue
+		}
+		if len(s.jobs) != 1 || s.Pending() {
+			t.Errorf("%q started %d jobs, want 1 without waiting for a ';'", tt.line, len(s.jobs))
+			continue
+		}
+		<-s.jobs[0].done
+		if s.jobs[0].Query != "select 1" {
+			t.Errorf("%q ran %q in the background, want select 1", tt.line, s.jobs[0].Query)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// TypedJsonValue converts a value returned by Athena into the closest native
+// JSON value using the column type, a nil value is a real null
+func TypedJsonValue(val *string, colType string) interface{} {
+	if val == nil {
+		return nil
+	}
+	return typedValue(*val, parseAthenaType(colType))
+}
+
+func typedValue(v string, t *athenaType) interface{} {
+	switch t.name {
+	case "tinyint", "smallint", "integer", "int", "bigint":
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return json.Number(v)
+		}
+	case "double", "float", "real", "decimal":
+		// NaN and Infinity have no JSON representation so they stay as strings
+		if isJsonNumber(v) {
+			return json.Number(v)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	case "json":
+		if json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+	case "array", "map", "row":
+		if nested, ok := parseAthenaValue(v, t); ok {
+			return nested
+		}
+	}
+	// numbers can only be empty when they come from an S3 result file
+	if v == "" && t.name != "" && !isStringType(t.name) {
+		return nil
+	}
+	return v
+}
+
+// athenaType is a column type such as array(map(varchar, integer)), Athena
+// often only reports the outer type, e.g. array, and then args is empty
+type athenaType struct {
+	name   string
+	args   []*athenaType
+	fields []string // the field names of a row, one for each of args
+}
+
+// parseAthenaType reads a column type, the types inside it are only filled
+// in if they can all be read
+func parseAthenaType(text string) *athenaType {
+	text = strings.TrimSpace(text)
+	open := strings.IndexByte(text, '(')
+	if open < 0 || !strings.HasSuffix(text, ")") {
+		return &athenaType{name: strings.ToLower(text)}
+	}
+	t := &athenaType{name: strings.ToLower(strings.TrimSpace(text[:open]))}
+	for _, arg := range splitTypeArgs(text[open+1 : len(text)-1]) {
+		if t.name == "row" {
+			// each field is "name type"
+			bits := strings.SplitN(strings.TrimSpace(arg), " ", 2)
+			if len(bits) != 2 {
+				return &athenaType{name: t.name}
+			}
+			t.fields = append(t.fields, strings.Trim(bits[0], `"`))
+			arg = bits[1]
+		}
+		t.args = append(t.args, parseAthenaType(arg))
+	}
+	return t
+}
+
+// splitTypeArgs splits the arguments of a type on the commas between them
+func splitTypeArgs(text string) []string {
+	var args []string
+	depth, start := 0, 0
+	for i, c := range text {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, text[start:])
+}
+
+// elem returns the type of the i'th argument, or nil if it is not known
+func (t *athenaType) elem(i int) *athenaType {
+	if t == nil || i >= len(t.args) {
+		return nil
+	}
+	return t.args[i]
+}
+
+// field returns the type of a row field, or nil if it is not known
+func (t *athenaType) field(name string) *athenaType {
+	if t == nil {
+		return nil
+	}
+	for i, f := range t.fields {
+		if f == name {
+			return t.args[i]
+		}
+	}
+	return nil
+}
+
+// nested reports if values of the type are shown with brackets, an unknown
+// type might be
+func (t *athenaType) nested() bool {
+	if t == nil {
+		return true
+	}
+	switch t.name {
+	case "array", "map", "row":
+		return true
+	}
+	return false
+}
+
+func isStringType(colType string) bool {
+	switch strings.ToLower(colType) {
+	case "varchar", "char", "string":
+		return true
+	}
+	return false
+}
+
+func isJsonNumber(v string) bool {
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return false
+	}
+	// ParseFloat accepts forms JSON does not, e.g. "+1", ".5", "1." or "0x1p-2"
+	var n json.Number
+	return json.Unmarshal([]byte(v), &n) == nil
+}
+
+// parseAthenaValue parses the text Athena renders for array, map and row
+// columns, e.g. [1, 2], {a=1, b=x} into nested JSON values, ok is false if the
+// text could not be parsed unambiguously.  Elements are typed using t, those
+// of an unknown type are left as strings
+func parseAthenaValue(text string, t *athenaType) (interface{}, bool) {
+	p := athenaValueParser{text: text}
+	val, ok := p.value(t)
+	if !ok || p.pos != len(p.text) {
+		return nil, false
+	}
+	return val, true
+}
+
+type athenaValueParser struct {
+	text string
+	pos  int
+}
+
+func (p *athenaValueParser) value(t *athenaType) (interface{}, bool) {
+	if p.pos >= len(p.text) {
+		return "", true
+	}
+	if t.nested() {
+		switch p.text[p.pos] {
+		case '[':
+			return p.array(t)
+		case '{':
+			return p.object(t)
+		}
+	}
+	return p.scalar(t), true
+}
+
+func (p *athenaValueParser) array(t *athenaType) (interface{}, bool) {
+	p.pos++ // [
+	list := []interface{}{}
+	if p.consume("]") {
+		return list, true
+	}
+	for {
+		val, ok := p.value(t.elem(0))
+		if !ok {
+			return nil, false
+		}
+		list = append(list, val)
+		if p.consume("]") {
+			return list, true
+		}
+		if !p.consume(", ") {
+			return nil, false
+		}
+	}
+}
+
+func (p *athenaValueParser) object(t *athenaType) (interface{}, bool) {
+	p.pos++ // {
+	obj := map[string]interface{}{}
+	if p.consume("}") {
+		return obj, true
+	}
+	for {
+		end := strings.IndexByte(p.text[p.pos:], '=')
+		if end <= 0 {
+			return nil, false
+		}
+		key := p.text[p.pos : p.pos+end]
+		if strings.ContainsAny(key, ",{}[]") {
+			return nil, false
+		}
+		p.pos += end + 1
+		if _, exists := obj[key]; exists {
+			return nil, false
+		}
+		valueType := t.field(key)
+		if t != nil && t.name == "map" {
+			valueType = t.elem(1)
+		}
+		val, ok := p.value(valueType)
+		if !ok {
+			return nil, false
+		}
+		obj[key] = val
+		if p.consume("}") {
+			return obj, true
+		}
+		if !p.consume(", ") {
+			return nil, false
+		}
+	}
+}
+
+// scalar reads up to the next separator or closing bracket and types it with
+// t, without a type it is a string as "1", "true" or "null" could be text
+func (p *athenaValueParser) scalar(t *athenaType) interface{} {
+	start := p.pos
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if c == ']' || c == '}' || strings.HasPrefix(p.text[p.pos:], ", ") {
+			break
+		}
+		p.pos++
+	}
+	v := p.text[start:p.pos]
+	if t == nil {
+		return v
+	}
+	// Athena shows a null element as null, which can only be told apart
+	// from text when the element isn't a string
+	if v == "null" && !isStringType(t.name) {
+		return nil
+	}
+	return typedValue(v, t)
+}
+
+func (p *athenaValueParser) consume(s string) bool {
+	if strings.HasPrefix(p.text[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestTypedJsonValue(t *testing.T) {
+	tests := []struct {
+		value   *string
+		colType string
+		want    string
+	}{
+		{nil, "varchar", `null`},
+		{aws.String("abc"), "varchar", `"abc"`},
+		{aws.String("123"), "varchar", `"123"`},
+		{aws.String("123"), "bigint", `123`},
+		{aws.String("12x"), "integer", `"12x"`},
+		{aws.String(""), "integer", `null`},
+		{aws.String(""), "varchar", `""`},
+		{aws.String("1.5"), "double", `1.5`},
+		{aws.String("12.50"), "decimal(10,2)", `12.50`},
+		{aws.String("NaN"), "double", `"NaN"`},
+		{aws.String("true"), "boolean", `true`},
+		{aws.String(`{"a":1}`), "json", `{"a":1}`},
+		{aws.String("2023-01-01"), "date", `"2023-01-01"`},
+
+		// without element types elements are strings
+		{aws.String("[1, 2]"), "array", `["1","2"]`},
+		{aws.String("[true, null, 123]"), "array", `["true","null","123"]`},
+		{aws.String("{a=1, b=x}"), "row", `{"a":"1","b":"x"}`},
+		{aws.String("[[1], [2, 3]]"), "array", `[["1"],["2","3"]]`},
+
+		// with element types they are typed
+		{aws.String("[1, 2]"), "array(integer)", `[1,2]`},
+		{aws.String("[true, null, 123]"), "array(varchar)", `["true","null","123"]`},
+		{aws.String("[true, null, false]"), "array(boolean)", `[true,null,false]`},
+		{aws.String("{a=1, b=2}"), "map(varchar, bigint)", `{"a":1,"b":2}`},
+		{aws.String("{id=1, name=123}"), "row(id integer, name varchar)", `{"id":1,"name":"123"}`},
+		{aws.String("[[1], [2, 3]]"), "array(array(integer))", `[[1],[2,3]]`},
+		{aws.String("{k=[1, 2]}"), "map(varchar, array(double))", `{"k":[1,2]}`},
+		{aws.String("[[x]]"), "array(varchar)", `"[[x]]"`},
+
+		// ambiguous text stays a string
+		{aws.String("[a, b"), "array(varchar)", `"[a, b"`},
+		{aws.String("{a=1, a=2}"), "map(varchar, integer)", `"{a=1, a=2}"`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(TypedJsonValue(tt.value, tt.colType))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("TypedJsonValue(%q, %q) = %s, want %s", aws.ToString(tt.value), tt.colType, got, tt.want)
+		}
+	}
+}
+
+func TestParseAthenaType(t *testing.T) {
+	typ := parseAthenaType("row(id integer, tags array(varchar), attrs map(varchar, row(x double, y double)))")
+	if typ.name != "row" || len(typ.args) != 3 {
+		t.Fatalf("parsed %+v", typ)
+	}
+	if got := typ.field("tags").elem(0).name; got != "varchar" {
+		t.Errorf("tags element is %q, want varchar", got)
+	}
+	if got := typ.field("attrs").elem(1).field("y").name; got != "double" {
+		t.Errorf("attrs value y is %q, want double", got)
+	}
+	if typ.field("missing") != nil {
+		t.Error("unknown field has a type")
+	}
+}
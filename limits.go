@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseBytes parses a size such as 50GB or 1.5 TB, units are powers of 1024
+// and a plain number is bytes
+func ParseBytes(text string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(text))
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("'%s' is not a size, use a number followed by B, KB, MB, GB or TB", text)
+	}
+	return int64(number * float64(unit)), nil
+}
+
+// FormatBytes returns a size in the largest unit it has at least one of
+func FormatBytes(size int64) string {
+	for _, u := range byteUnits {
+		if size >= u.size && u.size > 1 {
+			return strconv.FormatFloat(float64(size)/float64(u.size), 'f', 2, 64) + " " + u.suffix
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// SetLimit sets the scan limit for each query or for the session, "off"
+// removes it
+func (s *Session) SetLimit(kind string, size string) error {
+	limit := int64(0)
+	if size != "off" {
+		var err error
+		limit, err = ParseBytes(size)
+		if err != nil {
+			return err
+		}
+	}
+	switch kind {
+	case "scan":
+		s.ScanLimit = limit
+	case "session":
+		s.SessionScanLimit = limit
+	default:
+		return fmt.Errorf(".limit expects either 'scan' or 'session', '%s' is unknown", kind)
+	}
+	return nil
+}
+
+func (s *Session) listLimits() {
+	describe := func(limit int64) string {
+		if limit == 0 {
+			return "off"
+		}
+		return FormatBytes(limit)
+	}
+	fmt.Printf("scan\t%s\n", describe(s.ScanLimit))
+	fmt.Printf("session\t%s\n", describe(s.SessionScanLimit))
+	fmt.Printf("Scanned this session: %s\n", FormatBytes(s.scannedTotal))
+}
+
+// checkSessionBudget returns an error once the session has scanned as much
+// data as it is allowed to
+func (s *Session) checkSessionBudget() error {
+	if s.SessionScanLimit > 0 && s.scannedTotal >= s.SessionScanLimit {
+		return fmt.Errorf("session scan limit of %s has been reached (%s scanned), use '.limit session' to raise it",
+			FormatBytes(s.SessionScanLimit), FormatBytes(s.scannedTotal))
+	}
+	return nil
+}
+
+// scanLimitCheck returns a function for MonitorQuery which stops monitoring
+// once a query has scanned more than the per query limit, the amount scanned
+// is kept in scanned
+func (s *Session) scanLimitCheck(scanned *int64) func(exec *types.QueryExecution) error {
+	return func(exec *types.QueryExecution) error {
+		if exec.Statistics == nil {
+			return nil
+		}
+		*scanned = aws.ToInt64(exec.Statistics.DataScannedInBytes)
+		if s.ScanLimit > 0 && *scanned > s.ScanLimit {
+			return &ScanLimitError{Scanned: *scanned, Limit: s.ScanLimit}
+		}
+		return nil
+	}
+}
+
+// ScanLimitError is returned when a query scans more than it is allowed to
+type ScanLimitError struct {
+	Scanned int64
+	Limit   int64
+}
+
+func (e *ScanLimitError) Error() string {
+	return fmt.Sprintf("query has scanned %s which is over the limit of %s", FormatBytes(e.Scanned), FormatBytes(e.Limit))
+}
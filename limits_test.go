@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"1KB", 1 << 10, false},
+		{"50GB", 50 << 30, false},
+		{"50gb", 50 << 30, false},
+		{"1.5 TB", 3 << 39, false},
+		{" 2 MB ", 2 << 20, false},
+		{"", 0, true},
+		{"GB", 0, true},
+		{"-1GB", 0, true},
+		{"ten GB", 0, true},
+		{"5PB", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBytes(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.00 KB"},
+		{3 << 39, "1.50 TB"},
+		{50 << 30, "50.00 GB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.size); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestLimitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		scan    int64
+		session int64
+		wantErr bool
+	}{
+		{".limit scan 50GB", 50 << 30, 0, false},
+		{".limit scan 1.5 TB", 3 << 39, 0, false},
+		{".limit session 500 GB", 0, 500 << 30, false},
+		{".limit scan off", 0, 0, false},
+		{".limit scan", 0, 0, true},
+		{".limit bytes 1GB", 0, 0, true},
+		{".limit scan lots", 0, 0, true},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		_, err := s.ProcessCommand(tt.command)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q error = %v, want error %v", tt.command, err, tt.wantErr)
+		}
+		if s.ScanLimit != tt.scan || s.SessionScanLimit != tt.session {
+			t.Errorf("%q set limits %d and %d, want %d and %d", tt.command, s.ScanLimit, s.SessionScanLimit, tt.scan, tt.session)
+		}
+	}
+}
+
+func TestSessionBudget(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.SessionScanLimit = 1 << 20
+	s.scannedTotal = 1 << 20
+	captureOutput(t, func() {
+		s.SendLine("select 1;")
+	})
+	if len(fake.Started) != 0 {
+		t.Error("query was started after the session scan limit was reached")
+	}
+}
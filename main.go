@@ -8,42 +8,40 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/peterh/liner"
 )
 
-var workGroup string
-var database string
-
-var outputMode string = "ascii"
-var jsonMode string = "array"
-var ddlEnabled bool = false
-var showStats bool = false
-var showHeader bool = true
-var outputFile string = ""
-
-var mode = 0 // 0 means this is a new line, 1 means an extension of a previous line
-var query = ""
-
-func ProcessCommand(command string, cfg aws.Config, ctx context.Context) (bool, error) {
+func (s *Session) ProcessCommand(command string) (bool, error) {
 	bits := strings.Split(command, " ")
 	switch bits[0] {
 	case ".quit":
+		s.ReportRunningJobs()
+		s.printCostTotals()
 		fmt.Println("Goodbye.")
-		os.Exit(0)
+		s.quit = true
 		return true, nil
 	case ".exit":
+		s.ReportRunningJobs()
+		s.printCostTotals()
 		fmt.Println("Goodbye.")
-		os.Exit(0)
+		s.quit = true
 		return true, nil
 	case ".help":
 		DisplayHelp()
 		return true, nil
 	case ".save":
-		err := WriteConfig(database, workGroup)
+		err := WriteConfig(s.Catalog, s.Database, s.WorkGroup)
 		if err != nil {
 			fmt.Println("Error: failed to write config", err)
 			return false, errors.New(".save failed")
@@ -51,18 +49,56 @@ func ProcessCommand(command string, cfg aws.Config, ctx context.Context) (bool,
 			fmt.Println("Saved config.")
 			return true, nil
 		}
+	case ".use":
+		if len(bits) != 2 {
+			return false, errors.New(".use expects a database, or catalog.database, as an argument")
+		}
+		useErr := s.UseDatabase(bits[1])
+		if useErr != nil {
+			return false, useErr
+		}
+		return true, nil
+	case ".catalog":
+		if len(bits) == 1 {
+			fmt.Println(s.Catalog)
+			return true, nil
+		}
+		if len(bits) != 2 {
+			return false, errors.New(".catalog expects a data catalog name as an argument")
+		}
+		catalogErr := s.SetCatalog(bits[1])
+		if catalogErr != nil {
+			return false, catalogErr
+		}
+		return true, nil
+	case ".workgroup":
+		if len(bits) == 1 {
+			fmt.Println(s.WorkGroup)
+			return true, nil
+		}
+		if len(bits) != 2 {
+			return false, errors.New(".workgroup expects a workgroup name as an argument")
+		}
+		workGroupErr := s.SetWorkGroup(bits[1])
+		if workGroupErr != nil {
+			return false, workGroupErr
+		}
+		fmt.Printf("Using workgroup %s\n", s.WorkGroup)
+		return true, nil
 	case ".schema":
-		_, err := GetSchema(database, workGroup, cfg, ctx)
+		schemaCtx := s.startQuery()
+		defer s.finishQuery()
+		_, err := s.GetSchema(schemaCtx)
 		if err != nil {
 			PrettyPrintAwsError(err)
 		}
 		return true, nil
 	case ".output":
 		if len(bits) != 2 {
-			outputFile = ""
+			s.OutputFile = ""
 			return true, nil
 		} else {
-			outputFile = bits[1]
+			s.OutputFile = bits[1]
 			return true, nil
 		}
 	case ".header":
@@ -71,10 +107,10 @@ func ProcessCommand(command string, cfg aws.Config, ctx context.Context) (bool,
 		} else {
 			switch bits[1] {
 			case "on":
-				showHeader = true
+				s.ShowHeader = true
 				return true, nil
 			case "off":
-				showHeader = false
+				s.ShowHeader = false
 				return true, nil
 			default:
 				return false, fmt.Errorf(".header expects either 'on' or 'off', '%s' is unknown", bits[1])
@@ -86,66 +122,314 @@ func ProcessCommand(command string, cfg aws.Config, ctx context.Context) (bool,
 		} else {
 			switch bits[1] {
 			case "on":
-				ddlEnabled = true
+				s.DdlEnabled = true
 				return true, nil
 			case "off":
-				ddlEnabled = false
+				s.DdlEnabled = false
 				return true, nil
 			default:
 				return false, fmt.Errorf(".ddl expects either 'on' or 'off', '%s' is unknown", bits[1])
 			}
 		}
+	case ".readonly":
+		if len(bits) != 2 {
+			return false, errors.New(".readonly expects an argument")
+		} else {
+			switch bits[1] {
+			case "on":
+				s.ReadOnly = true
+				return true, nil
+			case "off":
+				if s.readOnlyLocked {
+					return false, errors.New("read-only mode was set with --read-only and cannot be turned off")
+				}
+				s.ReadOnly = false
+				return true, nil
+			default:
+				return false, fmt.Errorf(".readonly expects either 'on' or 'off', '%s' is unknown", bits[1])
+			}
+		}
+	case ".limit":
+		if len(bits) == 1 {
+			s.listLimits()
+			return true, nil
+		}
+		if len(bits) < 3 {
+			return false, errors.New(".limit expects 'scan' or 'session' and a size, e.g. 50GB, or 'off'")
+		}
+		// the size can have a space before its unit, e.g. 1.5 TB
+		limitErr := s.SetLimit(bits[1], strings.Join(bits[2:], " "))
+		if limitErr != nil {
+			return false, limitErr
+		}
+		return true, nil
+	case ".cost":
+		if len(bits) == 1 {
+			s.ShowCosts()
+			return true, nil
+		}
+		if len(bits) != 3 || bits[1] != "price" {
+			return false, errors.New(".cost expects no arguments or 'price' and the price per TB")
+		}
+		priceErr := s.SetPrice(bits[2])
+		if priceErr != nil {
+			return false, priceErr
+		}
+		return true, nil
 	case ".stats":
 		if len(bits) != 2 {
 			return false, errors.New(".stats expects an argument")
 		} else {
 			switch bits[1] {
 			case "on":
-				showStats = true
+				s.ShowStats = true
+				s.FullStats = false
+				return true, nil
+			case "full":
+				s.ShowStats = true
+				s.FullStats = true
 				return true, nil
 			case "off":
-				showStats = false
+				s.ShowStats = false
+				s.FullStats = false
 				return true, nil
 			default:
-				return false, fmt.Errorf(".stats expects either 'on' or 'off', '%s' is unknown", bits[1])
+				return false, fmt.Errorf(".stats expects either 'on', 'full' or 'off', '%s' is unknown", bits[1])
 			}
 		}
+	case ".poll":
+		if len(bits) == 1 {
+			fmt.Printf("Polling every %s at first, backing off to at most %s\n", MinPollInterval, s.MaxPollInterval)
+			return true, nil
+		}
+		if len(bits) != 2 {
+			return false, errors.New(".poll expects the longest wait between checks on a query, e.g. 5s")
+		}
+		pollErr := s.SetMaxPoll(bits[1])
+		if pollErr != nil {
+			return false, pollErr
+		}
+		return true, nil
+	case ".timeout":
+		if len(bits) == 1 {
+			if s.Timeout == 0 {
+				fmt.Println("No timeout is set")
+			} else {
+				fmt.Printf("Queries are stopped after %s\n", s.Timeout)
+			}
+			return true, nil
+		}
+		if len(bits) != 2 {
+			return false, errors.New(".timeout expects a duration, e.g. 5m, or 'off'")
+		}
+		timeoutErr := s.SetTimeout(bits[1])
+		if timeoutErr != nil {
+			return false, timeoutErr
+		}
+		return true, nil
+	case ".bg":
+		query := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.Join(bits[1:], " ")), ";"))
+		if query == "" {
+			return false, errors.New(".bg expects a query to run in the background")
+		}
+		s.runStatement(query+" &", nil)
+		return true, nil
+	case ".jobs":
+		s.ReportJobs()
+		s.ListJobs()
+		return true, nil
+	case ".wait", ".result", ".kill":
+		if len(bits) != 2 {
+			return false, fmt.Errorf("%s expects a job number as an argument", bits[0])
+		}
+		var jobErr error
+		switch bits[0] {
+		case ".wait":
+			jobErr = s.WaitJob(bits[1])
+		case ".result":
+			jobErr = s.JobResult(bits[1])
+		case ".kill":
+			jobErr = s.KillJob(bits[1])
+		}
+		if jobErr != nil {
+			return false, jobErr
+		}
+		return true, nil
+	case ".attach", ".status":
+		if len(bits) != 2 {
+			return false, fmt.Errorf("%s expects a query id as an argument", bits[0])
+		}
+		var queryErr error
+		if bits[0] == ".attach" {
+			queryErr = s.AttachQuery(bits[1])
+		} else {
+			queryErr = s.ShowStatus(bits[1])
+		}
+		if queryErr != nil {
+			return false, queryErr
+		}
+		return true, nil
+	case ".history":
+		if len(bits) < 2 || bits[1] != "remote" {
+			return false, errors.New(".history expects 'remote', e.g. '.history remote 20 --state FAILED --text orders'")
+		}
+		historyErr := s.ShowRemoteHistory(bits[2:])
+		if historyErr != nil {
+			return false, historyErr
+		}
+		return true, nil
+	case ".rerun":
+		if len(bits) != 2 {
+			return false, errors.New(".rerun expects a query id as an argument")
+		}
+		rerunErr := s.Rerun(bits[1])
+		if rerunErr != nil {
+			return false, rerunErr
+		}
+		return true, nil
+	case ".log":
+		if len(bits) < 3 || (bits[1] != "search" && bits[1] != "show") {
+			return false, errors.New(".log expects 'search <text>' or 'show <n>'")
+		}
+		var logErr error
+		if bits[1] == "search" {
+			logErr = s.SearchQueryLog(strings.Join(bits[2:], " "))
+		} else {
+			logErr = s.ShowQueryLog(bits[2])
+		}
+		if logErr != nil {
+			return false, logErr
+		}
+		return true, nil
+	case ".timeline":
+		if len(bits) != 2 {
+			return false, errors.New(".timeline expects a query id as an argument")
+		}
+		timelineErr := s.ShowTimeline(bits[1])
+		if timelineErr != nil {
+			return false, timelineErr
+		}
+		return true, nil
 	case ".file":
+		if len(bits) == 4 && bits[1] == "--parallel" {
+			parallel, parseErr := strconv.Atoi(bits[2])
+			if parseErr != nil {
+				return false, fmt.Errorf("--parallel expects a number, '%s' is not one", bits[2])
+			}
+			s.Reset()
+			err := s.ReadFileParallel(bits[3], parallel)
+			s.Reset()
+			if err != nil {
+				return false, err
+			}
+			return true, nil
+		}
 		if len(bits) != 2 {
-			return false, errors.New(".file expects a filename as an argument")
+			return false, errors.New(".file expects a filename as an argument, optionally after '--parallel N'")
 		} else {
-			mode = 0
-			query = ""
-			err := ReadFile(bits[1], cfg, ctx)
-			mode = 0
-			query = ""
+			s.Reset()
+			err := s.ReadFile(bits[1])
+			s.Reset()
 			if err != nil {
 				return false, err
 			} else {
 				return true, nil
 			}
 		}
+	case ".fetch":
+		if len(bits) != 2 {
+			return false, errors.New(".fetch expects an argument")
+		} else {
+			switch bits[1] {
+			case "api":
+				s.FetchMode = "api"
+				return true, nil
+			case "s3":
+				s.FetchMode = "s3"
+				return true, nil
+			default:
+				return false, fmt.Errorf(".fetch expects either 'api' or 's3', '%s' is unknown", bits[1])
+			}
+		}
+	case ".param":
+		if len(bits) == 1 {
+			return false, errors.New(".param expects 'set', 'list' or 'clear'")
+		}
+		switch bits[1] {
+		case "set":
+			if len(bits) < 4 {
+				return false, errors.New(".param set expects a name and a value")
+			}
+			s.SetParam(bits[2], strings.Join(bits[3:], " "))
+			return true, nil
+		case "list":
+			s.listParams()
+			return true, nil
+		case "clear":
+			s.Params = nil
+			return true, nil
+		default:
+			return false, fmt.Errorf(".param expects either 'set', 'list' or 'clear', '%s' is unknown", bits[1])
+		}
+	case ".prepare":
+		if len(bits) < 3 {
+			return false, errors.New(".prepare expects a name and a query")
+		}
+		err := s.Prepare(bits[1], strings.Join(bits[2:], " "))
+		if err != nil {
+			PrettyPrintAwsError(err)
+		}
+		return true, nil
+	case ".prepared":
+		name := ""
+		if len(bits) == 2 {
+			name = bits[1]
+		}
+		err := s.ShowPrepared(name)
+		if err != nil {
+			PrettyPrintAwsError(err)
+		}
+		return true, nil
+	case ".exec":
+		if len(bits) < 2 {
+			return false, errors.New(".exec expects the name of a prepared statement")
+		}
+		args, err := SplitArgs(strings.Join(bits[2:], " "))
+		if err != nil {
+			return false, err
+		}
+		s.ExecPrepared(bits[1], args)
+		return true, nil
+	case ".unprepare":
+		if len(bits) != 2 {
+			return false, errors.New(".unprepare expects the name of a prepared statement")
+		}
+		err := s.Unprepare(bits[1])
+		if err != nil {
+			PrettyPrintAwsError(err)
+		}
+		return true, nil
 	case ".mode":
 		if len(bits) == 1 {
 			return false, errors.New(".mode expects an argument")
 		} else {
 			switch bits[1] {
 			case "csv":
-				outputMode = "csv"
+				s.OutputMode = "csv"
 				return true, nil
 			case "ascii":
-				outputMode = "ascii"
+				s.OutputMode = "ascii"
 				return true, nil
 			case "json":
 				if len(bits) == 3 {
 					switch bits[2] {
 					case "array":
-						outputMode = "json"
-						jsonMode = "array"
+						s.OutputMode = "json"
+						s.JsonMode = "array"
 						return true, nil
 					case "serde":
-						outputMode = "json"
-						jsonMode = "serde"
+						s.OutputMode = "json"
+						s.JsonMode = "serde"
 						return true, nil
 					default:
 						return false, fmt.Errorf("json mode '%s' is unknown", bits[2])
@@ -163,7 +447,7 @@ func ProcessCommand(command string, cfg aws.Config, ctx context.Context) (bool,
 	}
 }
 
-func ReadFile(file string, cfg aws.Config, ctx context.Context) error {
+func (s *Session) ReadFile(file string) error {
 	f, err := os.OpenFile(file, os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		return err
@@ -173,9 +457,19 @@ func ReadFile(file string, cfg aws.Config, ctx context.Context) error {
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		line := scanner.Text()
-		if len(line) > 0 {
-			if !strings.HasPrefix(line, "#") {
-				SendLine(line, cfg, ctx)
+		// blank lines only matter inside a string or comment
+		if len(line) > 0 || s.InLiteral() {
+			if s.Pending() || !strings.HasPrefix(line, "#") {
+				s.SendLine(line)
+				// there is no prompt to report finished jobs at
+				s.ReportJobs()
+				if s.quit {
+					break
+				}
+				if s.cancelled {
+					fmt.Printf("Stopped running %s\n", file)
+					break
+				}
 			}
 		}
 	}
@@ -186,91 +480,241 @@ func ReadFile(file string, cfg aws.Config, ctx context.Context) error {
 	return nil
 }
 
-func SendLine(text string, cfg aws.Config, ctx context.Context) {
-	// check if this is a command
-	if mode == 0 && strings.HasPrefix(text, ".") {
-		// this is a command, so we need to process it
-		_, commandErr := ProcessCommand(text, cfg, ctx)
-		if commandErr != nil {
-			fmt.Printf("Error: %s\n", commandErr)
+// OutputQueryResults writes the results of a query to the output using the
+// current mode, each page is written as it is fetched
+func (s *Session) OutputQueryResults(id string, queryRes QuerySummary, ctx context.Context) error {
+	out, err := OpenOutput(s.OutputFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := NewResultWriter(out, s.OutputMode, s.JsonMode, s.ShowHeader, queryRes.StmtType)
+	// only SELECT style queries write a CSV to the output location, anything
+	// else, including INSERT, CTAS and UNLOAD, is fetched through the API
+	if s.FetchMode == "s3" && writesResultCsv(queryRes) {
+		if s.S3 == nil {
+			return errors.New("fetching results from S3 is not available")
 		}
+		err = StreamS3Results(id, queryRes.OutputLocation, s.Client, s.S3, ctx, w.WritePage)
 	} else {
-		if strings.HasSuffix(text, ";") {
-			// trim ; from text
-			text = text[:len(text)-1]
-			query = query + " " + text
-			query = strings.Trim(query, " \t")
-			mode = 0
-
-			// check if this is ddl, if so we need to see if ddl is enabled, if not we don't run
-			// TODO add any missing DDL prefixes
-			if strings.HasPrefix(strings.ToUpper(query), "CREATE") ||
-				strings.HasPrefix(strings.ToUpper(query), "ALTER") ||
-				strings.HasPrefix(strings.ToUpper(query), "DROP") {
-
-				if !ddlEnabled {
-					fmt.Printf("Error: DDL not enabled\n")
-					mode = 0
-					query = ""
-				}
+		err = StreamQueryResults(id, s.Client, ctx, w.WritePage)
+	}
+	// the writer is closed even if a page failed so a JSON array is not left open
+	closeErr := w.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
 
-			}
+// writesResultCsv reports if a query left its results as a CSV file in the
+// output location
+func writesResultCsv(queryRes QuerySummary) bool {
+	if queryRes.StmtType != "DML" || queryRes.OutputLocation == "" || queryRes.Execution == nil {
+		return false
+	}
+	return ClassifyStatement(aws.ToString(queryRes.Execution.Query)) == CategoryRead
+}
 
-			// need to run query
-			id, queryErr := StartQueryExec(query, workGroup, database, cfg, ctx)
-			if queryErr != nil {
-				// there's a problem
-				// print the error and reset
-				PrettyPrintAwsError(queryErr)
-				mode = 0
-				query = ""
-			} else {
-				fmt.Printf("Query id: %s\n", id)
-				queryRes, getQueryErr := MonitorQuery(id, cfg, ctx)
-				if getQueryErr != nil {
-					PrettyPrintAwsError(getQueryErr)
-				} else {
-					if queryRes.Successful {
-						if showStats {
-							fmt.Printf("Stats: bytes scanned: %v, runtime: %v\n", *queryRes.Stats.DataScannedInBytes, *queryRes.Stats.EngineExecutionTimeInMillis)
-						}
-						// now we need to get the results
-						rows, columns, getResultsErr := GetQueryResults(id, cfg, ctx)
-						if getResultsErr != nil {
-							PrettyPrintAwsError(getResultsErr)
-						} else {
-							if outputMode == "json" {
-								ToJson(rows, columns, queryRes.StmtType, jsonMode, outputFile)
-							} else {
-
-								err := OutputResults(rows, columns, outputMode == "csv", showHeader, outputFile, queryRes.StmtType)
-								if err != nil {
-									PrettyPrintAwsError(err)
-								}
-							}
-						}
-					}
-				}
-			}
-			query = ""
+// RunQuery runs a query, waits for it to finish and writes the results to the
+// output, Ctrl-C cancels it through queryCtx
+func (s *Session) RunQuery(query string, params []string) {
+	// it is still part of the summary when a file is run in parallel
+	item := &batchItem{query: query, params: params}
+	started := time.Now()
+	defer func() {
+		item.elapsed = time.Since(started)
+		s.recordStatement(item)
+	}()
+
+	budgetErr := s.checkSessionBudget()
+	if budgetErr != nil {
+		fmt.Printf("Error: %s\n", budgetErr)
+		item.err = budgetErr
+		return
+	}
+
+	queryCtx := s.startQuery()
+	defer s.finishQuery()
+
+	id, queryErr := StartQueryWithRetry(query, s.WorkGroup, s.Catalog, s.Database, params, s.MaxPollInterval, s.Client, queryCtx)
+	if queryErr != nil {
+		s.LogQuery(NewQueryLogEntry(s.WorkGroup, s.Catalog, s.Database, "", query, QuerySummary{}, queryErr))
+		item.err = queryErr
+		if errors.Is(queryErr, context.Canceled) {
+			s.cancelled = true
+			fmt.Println("Query cancelled before Athena accepted it")
+			return
+		}
+		PrettyPrintAwsError(queryErr)
+		return
+	}
+	fmt.Printf("Query id: %s\n", id)
+	item.id = id
+	var scanned int64
+	checkScan := s.scanLimitCheck(&scanned)
+	progress := NewProgressLine(os.Stdout, s.ShowProgress)
+	queryRes, getQueryErr := MonitorQuery(id, s.MaxPollInterval, func(exec *types.QueryExecution) error {
+		progress.Update(exec, s.PricePerTB)
+		return checkScan(exec)
+	}, s.Client, queryCtx)
+	progress.Clear()
+	s.LogQuery(NewQueryLogEntry(s.WorkGroup, s.Catalog, s.Database, id, query, queryRes, getQueryErr))
+	if queryRes.Stats != nil {
+		scanned = aws.ToInt64(queryRes.Stats.DataScannedInBytes)
+	}
+	s.scannedTotal += scanned
+	item.res, item.err, item.scanned = queryRes, getQueryErr, scanned
+	if queryCtx.Err() != nil {
+		item.err = queryCtx.Err()
+	}
+	cost := s.recordCost(id, query, queryRes, scanned)
+	if errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+		// the timeout has passed so stop the query in Athena as well
+		stopErr := StopQuery(id, s.Client, s.Ctx)
+		if stopErr != nil {
+			PrettyPrintAwsError(stopErr)
+		}
+		fmt.Printf("Error: query %s timed out after %s and was stopped\n", id, s.Timeout)
+		return
+	}
+	if queryCtx.Err() != nil {
+		// the user pressed Ctrl-C so stop the query in Athena as well
+		s.cancelled = true
+		stopErr := StopQuery(id, s.Client, s.Ctx)
+		if stopErr != nil {
+			PrettyPrintAwsError(stopErr)
 		} else {
-			mode = 1
-			query = query + " " + text
+			fmt.Printf("Query %s cancelled\n", id)
+		}
+		return
+	}
+	if getQueryErr != nil {
+		var limitErr *ScanLimitError
+		if errors.As(getQueryErr, &limitErr) {
+			// the query is still running so it needs stopping
+			stopErr := StopQuery(id, s.Client, s.Ctx)
+			if stopErr != nil {
+				PrettyPrintAwsError(stopErr)
+			}
+			fmt.Printf("Error: %s, query %s stopped\n", getQueryErr, id)
+			return
+		}
+		PrettyPrintAwsError(getQueryErr)
+		return
+	}
+	if queryRes.Successful {
+		if s.FullStats {
+			PrintFullStats(queryRes.Execution, cost)
+		} else if s.ShowStats {
+			var runtime int64
+			if queryRes.Stats != nil {
+				runtime = aws.ToInt64(queryRes.Stats.EngineExecutionTimeInMillis)
+			}
+			fmt.Printf("Stats: bytes scanned: %v, runtime: %v, estimated cost: %s\n", scanned, runtime, FormatCost(cost))
+		}
+		// now we need to get the results
+		getResultsErr := s.OutputQueryResults(id, queryRes, queryCtx)
+		if errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+			item.err = queryCtx.Err()
+			fmt.Printf("Error: fetching results timed out after %s\n", s.Timeout)
+		} else if queryCtx.Err() != nil {
+			s.cancelled = true
+			item.err = queryCtx.Err()
+			fmt.Println("Fetching results cancelled")
+		} else if getResultsErr != nil {
+			item.err = getResultsErr
+			PrettyPrintAwsError(getResultsErr)
+		}
+	}
+}
+
+func (s *Session) SendLine(text string) {
+	// check if this is a command
+	if !s.splitter.Pending() && strings.HasPrefix(text, ".") {
+		// this is a command, so we need to process it, after any statements
+		// before it which are waiting to run in parallel
+		if !s.flushBatch() {
+			return
+		}
+		_, commandErr := s.ProcessCommand(text)
+		if commandErr != nil {
+			fmt.Printf("Error: %s\n", commandErr)
+		}
+		return
+	}
+
+	// a line can finish the current query and hold any number of others, and
+	// a query ending with & is finished without needing a ';'
+	statements := s.splitter.Feed(text)
+	if s.splitter.Pending() && !s.splitter.InLiteral() {
+		if _, background := splitBackground(s.splitter.Text()); background {
+			statements = append(statements, s.splitter.Feed(";")...)
+		}
+	}
+	for _, query := range statements {
+		s.runStatement(query, nil)
+		if s.cancelled {
+			// don't run the rest of the line after Ctrl-C
+			s.splitter.Reset()
+			return
 		}
 	}
 }
 
+// runStatement checks a statement is allowed and runs it, in the background
+// if it ends with &, or as part of a batch.  args are bound to its ?
+// placeholders, if args is nil the session parameters are used
+func (s *Session) runStatement(query string, args []string) {
+	// a trailing & runs the query in the background
+	query, background := splitBackground(query)
+
+	// check the statement is allowed by the session settings, if not we don't run
+	policyErr := s.CheckStatement(query)
+	if policyErr != nil {
+		fmt.Printf("Error: %s\n", policyErr)
+		s.recordStatement(&batchItem{query: query, err: policyErr})
+		return
+	}
+
+	// need to run query
+	params := args
+	var paramsErr error
+	if params == nil {
+		params, paramsErr = s.ExecutionParams(query)
+	}
+	if paramsErr != nil {
+		PrettyPrintAwsError(paramsErr)
+		s.recordStatement(&batchItem{query: query, err: paramsErr})
+	} else if background {
+		s.StartJob(query, params)
+	} else if !s.queueStatement(query, params) {
+		s.RunQuery(query, params)
+	}
+}
+
 func main() {
 	// need to get the parameters
 	workGroupParam := flag.String("work-group", "", "Work group the query should be executed in")
 	databaseParam := flag.String("database", "", "Which database should be used for the query")
+	catalogParam := flag.String("catalog", "", "Which data catalog the database is in, AwsDataCatalog if not set")
 	fileParam := flag.String("file", "", "File to be executed")
+	parallelParam := flag.Int("parallel", 1, "Number of independent statements from --file to run at the same time")
+	timeoutParam := flag.Duration("timeout", 0, "Stop any query which runs for longer than this, e.g. 5m")
+	readOnlyParam := flag.Bool("read-only", false, "Block every statement which writes data or metadata")
+	fakeParam := flag.Bool("fake", false, "Use an in-memory fake Athena backend instead of AWS")
+	var paramsParam ParamFlag
+	flag.Var(&paramsParam, "param", "Query parameter in the form name=value, can be repeated")
 	flag.Parse()
 
 	// print welcome
 	fmt.Printf("AthenaQuery %s\n", VERSION)
 	fmt.Println("Enter \".help\" for usage hints")
 
+	var workGroup string
+	var database string
+
 	// check if config file exists
 	savedCfg, cfgError := ReadConfig()
 	if cfgError != nil {
@@ -307,21 +751,36 @@ func main() {
 
 	// get AWS context
 	ctx := context.TODO()
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		log.Fatal("Error: could not get AWS credentials from default chain", err)
-	}
+	var cfg aws.Config
+	var client AthenaAPI
+	var s3Client S3API
+	if *fakeParam {
+		fmt.Println("Using fake Athena backend")
+		fake := NewFakeAthena()
+		fake.WorkGroups[workGroup] = "s3://fake-athena-results/"
+		fake.S3 = NewFakeS3()
+		client = fake
+		s3Client = fake.S3
+	} else {
+		var err error
+		cfg, err = config.LoadDefaultConfig(ctx)
+		if err != nil {
+			log.Fatal("Error: could not get AWS credentials from default chain", err)
+		}
 
-	// print account details
-	client := sts.NewFromConfig(cfg)
-	identity, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
-	if err != nil {
-		log.Fatal("Error: could not get AWS caller identity", err)
+		// print account details
+		stsClient := sts.NewFromConfig(cfg)
+		identity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			log.Fatal("Error: could not get AWS caller identity", err)
+		}
+		fmt.Printf("Account ID: %s, Identity Arn: %s\n", aws.ToString(identity.Account), aws.ToString(identity.Arn))
+		client = athena.NewFromConfig(cfg)
+		s3Client = s3.NewFromConfig(cfg)
 	}
-	fmt.Printf("Account ID: %s, Identity Arn: %s\n", aws.ToString(identity.Account), aws.ToString(identity.Arn))
 
 	// check workgroup
-	workGroupOkay, checkWgErr := CheckWorkGroup(workGroup, cfg, ctx)
+	workGroupOkay, checkWgErr := CheckWorkGroup(workGroup, client, ctx)
 	if checkWgErr != nil {
 		PrettyPrintAwsError(checkWgErr)
 		os.Exit(1)
@@ -331,37 +790,146 @@ func main() {
 		os.Exit(1)
 	}
 
+	session := NewSession(ctx, client, workGroup, database)
+	if *catalogParam != "" {
+		session.Catalog = *catalogParam
+	} else if savedCfg.Catalog != "" && *databaseParam == "" {
+		// a saved catalog only goes with the saved database
+		session.Catalog = savedCfg.Catalog
+	}
+	session.S3 = s3Client
+	session.ShowProgress = IsTerminal(os.Stdout)
+	session.Timeout = *timeoutParam
+	queryLog, queryLogErr := QueryLogFile()
+	if queryLogErr != nil {
+		fmt.Println("Error: could not find the query log", queryLogErr)
+	}
+	session.QueryLog = queryLog
+	if *readOnlyParam {
+		session.ReadOnly = true
+		session.readOnlyLocked = true
+	}
+	for _, name := range savedCfg.ReadOnlyAllow {
+		category, err := ParseCategory(name)
+		if err != nil {
+			fmt.Printf("Error: %s in readonly_allow\n", err)
+			os.Exit(1)
+		}
+		session.ReadOnlyAllow[category] = true
+	}
+	if savedCfg.MaxPollInterval != "" {
+		pollErr := session.SetMaxPoll(savedCfg.MaxPollInterval)
+		if pollErr != nil {
+			fmt.Printf("Error: %s in max_poll_interval\n", pollErr)
+			os.Exit(1)
+		}
+	}
+	if savedCfg.PricePerTB > 0 {
+		session.PricePerTB = savedCfg.PricePerTB
+	}
+	for kind, size := range map[string]string{"scan": savedCfg.ScanLimit, "session": savedCfg.SessionScanLimit} {
+		if size == "" {
+			continue
+		}
+		limitErr := session.SetLimit(kind, size)
+		if limitErr != nil {
+			fmt.Printf("Error: %s in %s limit\n", limitErr, kind)
+			os.Exit(1)
+		}
+	}
+	for _, param := range paramsParam {
+		session.SetParam(param.Name, param.Value)
+	}
+
+	// Ctrl-C cancels the running query, at the prompt it is handled by the
+	// line editor and clears the input instead
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+			session.Interrupt()
+		}
+	}()
+
 	if *fileParam != "" {
 		fmt.Printf("Executing: %s\n", *fileParam)
-		err := ReadFile(*fileParam, cfg, ctx)
+		var err error
+		if *parallelParam > 1 {
+			err = session.ReadFileParallel(*fileParam, *parallelParam)
+		} else {
+			err = session.ReadFile(*fileParam)
+		}
 		if err != nil {
 			PrettyPrintAwsError(err)
 		}
+		if session.Quit() {
+			os.Exit(0)
+		}
 	}
 
-	// into the main loop
-	reader := bufio.NewReader(os.Stdin)
+	// line editor with history that is kept between sessions
+	line := liner.NewLiner()
+	line.SetCtrlCAborts(true)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetWordCompleter(session.Complete)
+	historyErr := ReadHistory(line)
+	if historyErr != nil {
+		fmt.Println("Error: could not read history", historyErr)
+	}
+	exit := func(code int) {
+		line.Close()
+		os.Exit(code)
+	}
 
+	// lines of a query are kept so they can be recalled as one history entry
+	var entry []string
+
+	// into the main loop
 	for {
-		if mode == 0 {
-			// new line
-			fmt.Print("athenaquery> ")
-		} else {
-			// continuation of previous line
-			fmt.Print("        ...> ")
+		session.ReportJobs()
+		text, err := line.Prompt(session.Prompt())
+		if err == liner.ErrPromptAborted {
+			session.Reset()
+			entry = nil
+			continue
+		}
+		if err != nil {
+			// end of input
+			fmt.Println()
+			session.ReportRunningJobs()
+			session.printCostTotals()
+			fmt.Println("Goodbye.")
+			exit(0)
 		}
-		text, _ := reader.ReadString('\n')
-		text = strings.Replace(text, "\n", "", -1)
 
-		// if empty just skip to next
-		if text == "" {
-			mode = 0
-			query = ""
+		// if empty just skip to next, unless it is part of a string or comment
+		if text == "" && !session.InLiteral() {
+			session.Reset()
+			entry = nil
 			continue
 		}
 
-		SendLine(text, cfg, ctx)
-
+		entry = append(entry, text)
+		session.SendLine(text)
+		if !session.Pending() {
+			if joined, ok := JoinLines(entry); ok {
+				line.AppendHistory(joined)
+			} else {
+				// history entries are single lines, so a string which spans
+				// lines is kept exactly by recalling it a line at a time
+				for _, text := range entry {
+					line.AppendHistory(text)
+				}
+			}
+			entry = nil
+			historyErr := WriteHistory(line)
+			if historyErr != nil {
+				fmt.Println("Error: could not write history", historyErr)
+			}
+		}
+		if session.Quit() {
+			exit(0)
+		}
 	}
 
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// newTestSession returns a session backed by FakeAthena which writes no
+// query log
+func newTestSession(t *testing.T) (*Session, *FakeAthena) {
+	t.Helper()
+	fake := NewFakeAthena()
+	fake.WorkGroups["primary"] = "s3://fake-athena-results/"
+	s := NewSession(context.Background(), fake, "primary", "db")
+	s.MaxPollInterval = MinPollInterval
+	return s, fake
+}
+
+// captureOutput returns what f prints to stdout
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	var out bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&out, r)
+		close(copied)
+	}()
+	f()
+	w.Close()
+	<-copied
+	return out.String()
+}
+
+// noStatsAthena reports queries without any statistics, as Athena can for
+// queries which never ran
+type noStatsAthena struct {
+	*FakeAthena
+}
+
+func (f *noStatsAthena) GetQueryExecution(ctx context.Context, params *athena.GetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error) {
+	out, err := f.FakeAthena.GetQueryExecution(ctx, params, optFns...)
+	if err == nil {
+		out.QueryExecution.Statistics = nil
+	}
+	return out, err
+}
+
+// noStatsLines are what a query without statistics shows for its stats, with
+// and without .stats full
+var noStatsLines = map[bool]string{
+	false: "Stats: bytes scanned: 0, runtime: 0",
+	true:  fmt.Sprintf("  %-25s%s\n", "Engine execution time:", "-"),
+}
+
+// interruptWhenRunning presses Ctrl-C once a query has started
+func interruptWhenRunning(s *Session) {
+	go func() {
+		for !s.Interrupt() {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+}
+
+func TestCommandsRunAfterCancelledQuery(t *testing.T) {
+	s, fake := newTestSession(t)
+	fake.AddQuery("select slow", &FakeQuery{
+		States: []types.QueryExecutionState{types.QueryExecutionStateRunning},
+	})
+
+	interruptWhenRunning(s)
+	s.SendLine("select slow;")
+	if !s.cancelled {
+		t.Fatal("query was not cancelled")
+	}
+	if len(fake.executions) != 1 {
+		t.Fatalf("%d queries started, want 1", len(fake.executions))
+	}
+	for id, exec := range fake.executions {
+		if !exec.stopped {
+			t.Errorf("query %s was not stopped in Athena", id)
+		}
+	}
+
+	s.SendLine(".mode csv")
+	if s.OutputMode != "csv" {
+		t.Errorf("OutputMode = %q after a cancelled query, want csv", s.OutputMode)
+	}
+}
+
+func TestCancelledBatchSkipsLaterStatements(t *testing.T) {
+	s, fake := newTestSession(t)
+	fake.AddQuery("select slow", &FakeQuery{
+		States: []types.QueryExecutionState{types.QueryExecutionStateRunning},
+	})
+	s.DdlEnabled = true
+	s.batch = &statementBatch{parallel: 2}
+
+	interruptWhenRunning(s)
+	s.SendLine("select slow;")
+	s.SendLine("drop table t;")
+	if !s.cancelled {
+		t.Fatal("batch was not cancelled")
+	}
+	for _, started := range fake.Started {
+		if aws.ToString(started.QueryString) == "drop table t" {
+			t.Error("statement after a cancelled batch was run")
+		}
+	}
+}
+
+// stalledResults never returns a page of results before the context ends
+type stalledResults struct {
+	*FakeAthena
+}
+
+func (f *stalledResults) GetQueryResults(ctx context.Context, params *athena.GetQueryResultsInput, optFns ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutStopsQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(s *Session) string
+	}{
+		{"interactive", func(s *Session) string {
+			s.SendLine("select slow;")
+			return ""
+		}},
+		{"background", func(s *Session) string {
+			s.SendLine("select slow &")
+			job := s.jobs[0]
+			<-job.done
+			return job.err.Error()
+		}},
+		{"parallel", func(s *Session) string {
+			s.batch = &statementBatch{parallel: 2}
+			s.SendLine("select slow;")
+			s.flushBatch()
+			return s.batch.finished[0].err.Error()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestSession(t)
+			fake.AddQuery("select slow", &FakeQuery{
+				States: []types.QueryExecutionState{types.QueryExecutionStateRunning},
+			})
+			if _, err := s.ProcessCommand(".timeout 300ms"); err != nil {
+				t.Fatal(err)
+			}
+
+			var reported string
+			out := captureOutput(t, func() {
+				reported = tt.run(s)
+			})
+			if !strings.Contains(out+reported, "timed out after 300ms") {
+				t.Errorf("printed %q and reported %q, want a timeout", out, reported)
+			}
+			if len(fake.executions) != 1 {
+				t.Fatalf("%d queries started, want 1", len(fake.executions))
+			}
+			for id, exec := range fake.executions {
+				if !exec.stopped {
+					t.Errorf("query %s was not stopped in Athena", id)
+				}
+			}
+		})
+	}
+}
+
+func TestResultsTimeoutInBatchSummary(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.Client = &stalledResults{FakeAthena: fake}
+	s.Timeout = 300 * time.Millisecond
+	s.batch = &statementBatch{parallel: 2}
+
+	out := captureOutput(t, func() {
+		s.SendLine("insert into t values (1);")
+	})
+	if !strings.Contains(out, "fetching results timed out") {
+		t.Errorf("printed %q, want a timeout", out)
+	}
+	if len(s.batch.finished) != 1 || !errors.Is(s.batch.finished[0].err, context.DeadlineExceeded) {
+		t.Errorf("batch recorded %v, want the timeout", s.batch.finished)
+	}
+}
+
+// failingSecondPage returns the first page of results and then fails
+type failingSecondPage struct {
+	*FakeAthena
+}
+
+func (f *failingSecondPage) GetQueryResults(ctx context.Context, params *athena.GetQueryResultsInput, optFns ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error) {
+	if params.NextToken != nil {
+		return nil, errors.New("connection reset")
+	}
+	return f.FakeAthena.GetQueryResults(ctx, params, optFns...)
+}
+
+func TestJsonArrayClosedWhenResultsFail(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.Client = &failingSecondPage{FakeAthena: fake}
+	s.OutputMode = "json"
+	fake.AddQuery("select n from numbers", &FakeQuery{
+		StatementType: types.StatementTypeDml,
+		Pages: []types.ResultSet{
+			FakeResultSet([]string{"n"}, []string{"1"}),
+			FakeResultSet([]string{"n"}, []string{"2"}),
+		},
+	})
+
+	out := captureOutput(t, func() {
+		s.SendLine("select n from numbers;")
+	})
+	if !strings.Contains(out, `[{"n":"1"}]`) || !strings.Contains(out, "connection reset") {
+		t.Errorf("printed %q, want the first page as a closed array and the error", out)
+	}
+}
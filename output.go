@@ -3,157 +3,276 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/athena/types"
 	"github.com/jedib0t/go-pretty/v6/table"
 )
 
-func ToJson(rows []types.Row, columns []types.ColumnInfo, qryType string, jsonMode string, outFile string) error {
-	dict := []map[string]interface{}{}
-	output := ""
+// AsciiChunkRows is the number of rows buffered before an ascii table is
+// rendered, results bigger than this are printed as a series of tables
+const AsciiChunkRows = 10000
 
-	// we skip the first row (column names) unless it is a utility output
-	start := 1
-	if qryType == "UTILITY" {
-		start = 0
-	}
+// ResultWriter renders a result set one page at a time as it is fetched
+type ResultWriter interface {
+	WritePage(rows []types.Row, columns []types.ColumnInfo) error
+	Close() error
+}
 
-	for _, row := range rows[start:] {
-		data := map[string]interface{}{}
-		// for UTILITY type we need to split the first column by tabs first...
-		if qryType == "UTILITY" {
-			for i, col := range strings.Split(*row.Data[0].VarCharValue, "\t") {
-				if col != "" {
-					data[*columns[i].Name] = col
-				} else {
-					data[*columns[i].Name] = ""
-				}
-			}
-		} else {
-			for i, col := range row.Data {
-				if col.VarCharValue != nil {
-					data[*columns[i].Name] = *col.VarCharValue
-				} else {
-					data[*columns[i].Name] = ""
-				}
-			}
+// NewResultWriter returns a ResultWriter for the given output mode
+func NewResultWriter(out io.Writer, outputMode string, jsonMode string, header bool, qryType string) ResultWriter {
+	if outputMode == "json" {
+		return &jsonWriter{
+			out:     out,
+			serde:   jsonMode == "serde",
+			qryType: qryType,
 		}
-		dict = append(dict, data)
 	}
+	return &tableWriter{
+		out:     out,
+		csv:     outputMode == "csv",
+		header:  header,
+		qryType: qryType,
+	}
+}
 
-	if jsonMode == "serde" {
-		for _, row := range dict {
-			j, err := json.Marshal(row)
-			if err != nil {
-				return err
+// OpenOutput returns where results should be written, stdout if outFile is
+// blank otherwise the file is appended to
+func OpenOutput(outFile string) (io.WriteCloser, error) {
+	if outFile == "" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(outFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// rowValues returns the values in a row, for UTILITY type we need to split the
+// first column by tabs first
+func rowValues(row types.Row, qryType string) []*string {
+	var values []*string
+	if qryType == "UTILITY" {
+		for _, col := range strings.Split(*row.Data[0].VarCharValue, "\t") {
+			if col != "" {
+				values = append(values, aws.String(col))
+			} else {
+				values = append(values, nil)
 			}
-			output = output + string(j) + "\n"
 		}
 	} else {
-		j, err := json.Marshal(dict)
-		if err != nil {
-			return err
+		for _, col := range row.Data {
+			values = append(values, col.VarCharValue)
 		}
-		output = string(j) + "\n"
 	}
+	return values
+}
 
-	if outFile == "" {
-		fmt.Print(output)
-	} else {
-		// we are writing to a file
-		_, writeErr := WriteToFile(outFile, output)
-		if writeErr != nil {
-			return writeErr
+type jsonWriter struct {
+	out     io.Writer
+	serde   bool
+	qryType string
+	columns []types.ColumnInfo
+	started bool
+	count   int
+}
+
+func (w *jsonWriter) WritePage(rows []types.Row, columns []types.ColumnInfo) error {
+	if w.columns == nil {
+		w.columns = columns
+	}
+	// we skip the first row (column names) unless it is a utility output
+	if !w.started {
+		w.started = true
+		if w.qryType != "UTILITY" && len(rows) > 0 {
+			rows = rows[1:]
 		}
 	}
 
+	for _, row := range rows {
+		data := map[string]interface{}{}
+		for i, col := range rowValues(row, w.qryType) {
+			data[*w.columns[i].Name] = TypedJsonValue(col, aws.ToString(w.columns[i].Type))
+		}
+		j, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		output := string(j)
+		if w.serde {
+			output = output + "\n"
+		} else if w.count == 0 {
+			output = "[" + output
+		} else {
+			output = "," + output
+		}
+		if _, err := io.WriteString(w.out, output); err != nil {
+			return err
+		}
+		w.count++
+	}
 	return nil
-
 }
 
-func OutputResults(rows []types.Row, columns []types.ColumnInfo, csv bool, header bool, outFile string, qryType string) error {
-	t := table.NewWriter()
-	//t.SetOutputMirror(os.Stdout)
+func (w *jsonWriter) Close() error {
+	if w.serde {
+		return nil
+	}
+	output := "]\n"
+	if w.count == 0 {
+		output = "[]\n"
+	}
+	_, err := io.WriteString(w.out, output)
+	return err
+}
 
-	if header {
-		var header table.Row
-		header = append(header, "#")
+type tableWriter struct {
+	out     io.Writer
+	csv     bool
+	header  bool
+	qryType string
+	columns []types.ColumnInfo
+	started bool
+	table   table.Writer
+	pending int
+	count   int
+	flushed bool
+}
 
-		for _, col := range columns {
-			header = append(header, *col.Name)
-		}
-		t.AppendHeader(header)
+func (w *tableWriter) WritePage(rows []types.Row, columns []types.ColumnInfo) error {
+	if w.columns == nil {
+		w.columns = columns
 	}
-
 	// we skip the first row (column names) unless it is a utility output
-	start := 1
-	if qryType == "UTILITY" {
-		start = 0
+	if !w.started {
+		w.started = true
+		if w.qryType != "UTILITY" && len(rows) > 0 {
+			rows = rows[1:]
+		}
 	}
 
-	for i, row := range rows[start:] {
+	for _, row := range rows {
+		if w.table == nil {
+			w.newTable()
+		}
 		var data table.Row
-		data = append(data, i)
-		// for UTILITY type we need to split the first column by tabs first...
-		if qryType == "UTILITY" {
-			for _, col := range strings.Split(*row.Data[0].VarCharValue, "\t") {
-				if col != "" {
-					data = append(data, col)
-				} else {
-					data = append(data, "null")
-				}
+		data = append(data, w.count)
+		for _, col := range rowValues(row, w.qryType) {
+			if col != nil {
+				data = append(data, *col)
+			} else {
+				data = append(data, "null")
 			}
-		} else {
-			for _, col := range row.Data {
-				if col.VarCharValue != nil {
-					data = append(data, *col.VarCharValue)
-				} else {
-					data = append(data, "null")
-				}
+		}
+		w.table.AppendRow(data)
+		w.pending++
+		w.count++
+		if !w.csv && w.pending >= AsciiChunkRows {
+			if err := w.flush(); err != nil {
+				return err
 			}
 		}
-		t.AppendRow(data)
 	}
-	var output = ""
-	if csv {
-		output = t.RenderCSV()
-	} else {
-		output = t.Render()
+	// csv has no column widths to work out so each page is written straight away
+	if w.csv && w.pending > 0 {
+		return w.flush()
 	}
-	if outFile == "" {
-		fmt.Println(output)
-	} else {
-		// we are writing to a file
-		_, writeErr := WriteToFile(outFile, output)
-		if writeErr != nil {
-			return writeErr
+	return nil
+}
+
+func (w *tableWriter) Close() error {
+	// an empty result still gets a table so the header is shown
+	if w.pending > 0 || !w.flushed {
+		if w.table == nil {
+			w.newTable()
 		}
+		return w.flush()
 	}
 	return nil
 }
 
-func WriteToFile(fileName string, output string) (bool, error) {
-	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
-	if err != nil {
-		return false, err
+func (w *tableWriter) newTable() {
+	w.table = table.NewWriter()
+	// csv only needs the header once, ascii tables repeat it for each chunk
+	if w.header && (!w.csv || !w.flushed) {
+		var header table.Row
+		header = append(header, "#")
+
+		for _, col := range w.columns {
+			header = append(header, *col.Name)
+		}
+		w.table.AppendHeader(header)
+	}
+}
+
+func (w *tableWriter) flush() error {
+	var output = ""
+	if w.csv {
+		output = w.table.RenderCSV()
+	} else {
+		output = w.table.Render()
+	}
+	w.table = nil
+	w.pending = 0
+	w.flushed = true
+	_, err := io.WriteString(w.out, output+"\n")
+	return err
+}
+
+// abbreviate puts a query on one line and shortens it to at most length
+// characters, ending it with "..." if anything was cut off
+func abbreviate(query string, length int) string {
+	query = strings.Join(strings.Fields(query), " ")
+	runes := []rune(query)
+	if len(runes) <= length {
+		return query
 	}
-	file.WriteString(output + "\n")
-	file.Close()
-	return true, nil
+	return string(runes[:length-3]) + "..."
 }
 
 func DisplayHelp() {
-	fmt.Println(".ddl\t\tEnable or disable DDL statements 'CREATE', 'ALTER' and 'DROP'")
+	fmt.Println(".attach\t\tFollow a query started elsewhere and show its results, e.g. '.attach <query-id>'")
+	fmt.Println(".bg\t\tRun a query in the background, e.g. '.bg <sql>', ending a query with & does the same")
+	fmt.Println(".catalog\tShow or change the data catalog queries run in, e.g. '.catalog AwsDataCatalog'")
+	fmt.Println(".cost\t\tShow the estimated cost of each query in this session, '.cost price 5' sets the price per TB")
+	fmt.Println(".ddl\t\tEnable or disable DDL statements 'CREATE', 'ALTER', 'DROP', CTAS and 'MSCK'")
+	fmt.Println(".exec\t\tRun a prepared statement, e.g. .exec name arg1 arg2")
 	fmt.Println(".exit\t\tSynonym for quit")
-	fmt.Println(".file\t\tRun the commands in the file specified")
+	fmt.Println(".fetch\t\tFetch results through the Athena 'api' or directly from 's3'")
+	fmt.Println(".file\t\tRun the commands in the file specified, '.file --parallel N <file>' runs N queries at a time")
 	fmt.Println(".header\t\tTurn on or off display of result set headers (column names)")
 	fmt.Println(".help\t\tDisplay this message")
+	fmt.Println(".history\tList queries run in the workgroup, e.g. '.history remote [n] [--state FAILED] [--text orders]'")
+	fmt.Println(".jobs\t\tList the background queries with their state, elapsed time and data scanned")
+	fmt.Println(".kill\t\tStop a background query, e.g. '.kill 1'")
+	fmt.Println(".limit\t\tShow the scan limits or set them, e.g. '.limit scan 50GB' or '.limit session off'")
+	fmt.Println(".log\t\tSearch the log of queries run by this tool, e.g. '.log search <text>' or '.log show <n>'")
 	fmt.Println(".mode\t\tChange output mode")
 	fmt.Println(".output\t\tOutput to stdout or a file, if blank it uses stdout")
-	fmt.Println(".save\t\tSave the default work-group and database for next time")
+	fmt.Println(".param\t\tSet, list or clear the values bound to ? placeholders")
+	fmt.Println(".poll\t\tShow or set the longest wait between checks on a running query, e.g. '.poll 10s'")
+	fmt.Println(".prepare\tCreate a prepared statement in the workgroup, e.g. .prepare name <sql>")
+	fmt.Println(".prepared\tList the prepared statements in the workgroup, or show one by name")
+	fmt.Println(".readonly\tTurn on or off read-only mode which blocks statements that write data or metadata")
+	fmt.Println(".rerun\t\tRun the SQL of an earlier query again, e.g. '.rerun <query-id>'")
+	fmt.Println(".result\t\tShow the results of a finished background query, e.g. '.result 1'")
+	fmt.Println(".save\t\tSave the default work-group, catalog and database for next time")
 	fmt.Println(".schema\t\tPrint the schema of the database")
-	fmt.Println(".stats\t\tDisplay query stats")
+	fmt.Println(".stats\t\tDisplay query stats, use 'full' for timings, engine version and result reuse")
+	fmt.Println(".status\t\tShow the state, SQL and statistics of a query, e.g. '.status <query-id>'")
+	fmt.Println(".timeline\tShow how long each phase of a query took, e.g. '.timeline <query-id>'")
+	fmt.Println(".timeout\tShow or set how long a query can run before it is stopped, e.g. '.timeout 5m' or '.timeout off'")
 	fmt.Println(".quit\t\tExit this utility")
+	fmt.Println(".unprepare\tDelete a prepared statement from the workgroup")
+	fmt.Println(".use\t\tChange the database queries run in, e.g. '.use sales' or '.use catalog.sales'")
+	fmt.Println(".wait\t\tWait for a background query to finish, e.g. '.wait 1'")
+	fmt.Println(".workgroup\tShow or change the workgroup queries run in, e.g. '.workgroup primary'")
 }
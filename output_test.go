@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestAbbreviate(t *testing.T) {
+	tests := []struct {
+		query  string
+		length int
+		want   string
+	}{
+		{"select 1", 10, "select 1"},
+		{"select\n  *\tfrom t", 20, "select * from t"},
+		{"select * from orders", 10, "select ..."},
+		{"select 'ééééé'", 12, "select 'é..."},
+		{"select '日本語日本語'", 12, "select '日..."},
+	}
+	for _, tt := range tests {
+		got := abbreviate(tt.query, tt.length)
+		if got != tt.want {
+			t.Errorf("abbreviate(%q, %d) = %q, want %q", tt.query, tt.length, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("abbreviate(%q, %d) is not valid UTF-8", tt.query, tt.length)
+		}
+	}
+}
+
+// resultPages splits rows into pages of size rows the way Athena does, the
+// first page starts with the column names
+func resultPages(columns []string, rows [][]string, size int) []types.ResultSet {
+	var pages []types.ResultSet
+	for start := 0; start == 0 || start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		page := FakeResultSet(columns, rows[start:end]...)
+		if start > 0 {
+			page.Rows = page.Rows[1:]
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+func TestResultWriter(t *testing.T) {
+	rows := [][]string{{"1", "a"}, {"2", "b"}, {"3", "c"}}
+	tests := []struct {
+		name     string
+		mode     string
+		jsonMode string
+		header   bool
+		rows     [][]string
+		want     string
+	}{
+		{"csv header once", "csv", "", true, rows, "#,n,s\n0,1,a\n1,2,b\n2,3,c\n"},
+		{"csv without header", "csv", "", false, rows, "0,1,a\n1,2,b\n2,3,c\n"},
+		{"csv empty", "csv", "", true, nil, "#,n,s\n"},
+		{"json array", "json", "array", true, rows, `[{"n":"1","s":"a"},{"n":"2","s":"b"},{"n":"3","s":"c"}]` + "\n"},
+		{"json empty array", "json", "array", true, nil, "[]\n"},
+		{"json serde", "json", "serde", true, rows, `{"n":"1","s":"a"}` + "\n" + `{"n":"2","s":"b"}` + "\n" + `{"n":"3","s":"c"}` + "\n"},
+		{"json serde empty", "json", "serde", true, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewResultWriter(&out, tt.mode, tt.jsonMode, tt.header, "DML")
+			for _, page := range resultPages([]string{"n", "s"}, tt.rows, 2) {
+				if err := w.WritePage(page.Rows, page.ResultSetMetadata.ColumnInfo); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsciiResultsChunked(t *testing.T) {
+	var rows [][]string
+	for i := 0; i < AsciiChunkRows+5; i++ {
+		rows = append(rows, []string{strconv.Itoa(i)})
+	}
+	var out bytes.Buffer
+	w := NewResultWriter(&out, "ascii", "", true, "DML")
+	for _, page := range resultPages([]string{"n"}, rows, 1000) {
+		if err := w.WritePage(page.Rows, page.ResultSetMetadata.ColumnInfo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// each chunk is its own table with the header repeated
+	got := out.String()
+	if headers := strings.Count(got, " # |"); headers != 2 {
+		t.Errorf("%d tables written, want 2", headers)
+	}
+	last := got[strings.LastIndex(got, " # |"):]
+	if lines := strings.Count(last, "\n| "); lines != 5 {
+		t.Errorf("last table has %d rows, want 5:\n%s", lines, last)
+	}
+	if !strings.Contains(last, fmt.Sprintf("| %d | %d |", AsciiChunkRows+4, AsciiChunkRows+4)) {
+		t.Errorf("row numbers do not carry on between tables:\n%s", last)
+	}
+}
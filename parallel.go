@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// statementBatch collects read-only statements from a file so they can be
+// run at the same time, anything else waits for the batch to finish first
+type statementBatch struct {
+	parallel int
+	pending  []*batchItem
+	finished []*batchItem // every statement run, for the summary
+}
+
+// batchItem is one statement in a batch and what happened when it ran
+type batchItem struct {
+	query   string
+	params  []string
+	id      string
+	res     QuerySummary
+	err     error
+	scanned int64
+	elapsed time.Duration
+	stopErr error // why a cancelled query could not be stopped in Athena
+	done    chan struct{}
+}
+
+// ReadFileParallel runs a file like ReadFile, but up to parallel independent
+// statements are in flight at once, results are still shown in the order
+// the statements appear in the file
+func (s *Session) ReadFileParallel(file string, parallel int) error {
+	if parallel < 1 {
+		return fmt.Errorf("the number of statements to run in parallel must be at least 1, not %d", parallel)
+	}
+	outer := s.batch
+	s.batch = &statementBatch{parallel: parallel}
+	defer func() {
+		s.batch = outer
+	}()
+
+	err := s.ReadFile(file)
+	if !s.cancelled {
+		s.flushBatch()
+	}
+	s.printBatchSummary()
+	return err
+}
+
+// queueStatement adds a statement to the batch if it can run alongside the
+// others, it returns false if the statement has to be run on its own.  A
+// statement which was waiting for a cancelled batch is dropped
+func (s *Session) queueStatement(query string, params []string) bool {
+	if s.batch == nil {
+		return false
+	}
+	category := ClassifyStatement(query)
+	if name := executedStatementName(query); name != "" {
+		// a prepared statement can run alongside the others if its query only
+		// reads, if it can't be checked it runs on its own
+		category, _ = s.preparedCategory(name)
+	}
+	if category != CategoryRead {
+		// it might change what later statements see
+		return !s.flushBatch()
+	}
+	s.batch.pending = append(s.batch.pending, &batchItem{query: query, params: params, done: make(chan struct{})})
+	return true
+}
+
+// recordStatement adds a statement which did not run as part of a batch to
+// the summary of a file being run in parallel
+func (s *Session) recordStatement(item *batchItem) {
+	if s.batch != nil {
+		s.batch.finished = append(s.batch.finished, item)
+	}
+}
+
+// flushBatch runs the statements waiting in the batch, it returns false if
+// they were cancelled with Ctrl-C
+func (s *Session) flushBatch() bool {
+	if s.batch == nil || len(s.batch.pending) == 0 {
+		return true
+	}
+	items := s.batch.pending
+	s.batch.pending = nil
+	s.batch.finished = append(s.batch.finished, items...)
+
+	budgetErr := s.checkSessionBudget()
+	if budgetErr != nil {
+		fmt.Printf("Error: %s\n", budgetErr)
+		for _, item := range items {
+			item.err = budgetErr
+		}
+		return true
+	}
+
+	// each statement has its own timeout so the batch only ends with Ctrl-C
+	batchCtx := s.startQueryWithin(0)
+	defer s.finishQuery()
+
+	slots := make(chan struct{}, s.batch.parallel)
+	for _, item := range items {
+		go s.runBatchItem(item, slots, batchCtx)
+	}
+
+	// results are shown in order, each as soon as it and those before it are done
+	for _, item := range items {
+		<-item.done
+		s.scannedTotal += item.scanned
+		if item.id == "" {
+			// never started
+			if item.err != nil && batchCtx.Err() == nil {
+				PrettyPrintAwsError(item.err)
+			}
+			continue
+		}
+		fmt.Printf("Query id: %s\n", item.id)
+		cost := s.recordCost(item.id, item.query, item.res, item.scanned)
+		if item.err != nil {
+			if batchCtx.Err() != nil {
+				fmt.Printf("Query %s cancelled\n", item.id)
+				if item.stopErr != nil {
+					PrettyPrintAwsError(item.stopErr)
+				}
+			} else {
+				fmt.Printf("Error: %s\n", item.err)
+			}
+			continue
+		}
+		if s.FullStats {
+			PrintFullStats(item.res.Execution, cost)
+		} else if s.ShowStats {
+			var runtime int64
+			if item.res.Stats != nil {
+				runtime = aws.ToInt64(item.res.Stats.EngineExecutionTimeInMillis)
+			}
+			fmt.Printf("Stats: bytes scanned: %v, runtime: %v, estimated cost: %s\n", item.scanned, runtime, FormatCost(cost))
+		}
+		if batchCtx.Err() == nil {
+			resultsErr := s.OutputQueryResults(item.id, item.res, batchCtx)
+			if resultsErr != nil && batchCtx.Err() == nil {
+				PrettyPrintAwsError(resultsErr)
+			}
+		}
+	}
+	if batchCtx.Err() != nil {
+		s.cancelled = true
+		return false
+	}
+	return true
+}
+
+// runBatchItem runs one statement once there is a free slot, the query is
+// stopped in Athena if it is cancelled, times out or scans too much
+func (s *Session) runBatchItem(item *batchItem, slots chan struct{}, ctx context.Context) {
+	defer close(item.done)
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		item.err = ctx.Err()
+		return
+	}
+
+	queryCtx, cancel := withTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	item.id, item.err = StartQueryWithRetry(item.query, s.WorkGroup, s.Catalog, s.Database, item.params, s.MaxPollInterval, s.Client, queryCtx)
+	if item.err != nil {
+		item.id = ""
+		s.LogQuery(NewQueryLogEntry(s.WorkGroup, s.Catalog, s.Database, "", item.query, QuerySummary{}, item.err))
+		return
+	}
+	item.res, item.err = MonitorQuery(item.id, s.MaxPollInterval, s.scanLimitCheck(&item.scanned), s.Client, queryCtx)
+	s.LogQuery(NewQueryLogEntry(s.WorkGroup, s.Catalog, s.Database, item.id, item.query, item.res, item.err))
+	item.elapsed = time.Since(started)
+	if item.res.Stats != nil {
+		item.scanned = aws.ToInt64(item.res.Stats.DataScannedInBytes)
+	}
+
+	var limitErr *ScanLimitError
+	if queryCtx.Err() != nil || errors.As(item.err, &limitErr) {
+		stopErr := StopQuery(item.id, s.Client, s.Ctx)
+		if ctx.Err() != nil {
+			// Ctrl-C is reported for the whole batch
+			item.err, item.stopErr = ctx.Err(), stopErr
+			return
+		}
+		if errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+			item.err = fmt.Errorf("query %s timed out after %s", item.id, s.Timeout)
+		}
+		if stopErr != nil {
+			item.err = fmt.Errorf("%v, and could not be stopped: %v", item.err, stopErr)
+		} else {
+			item.res.State = string(types.QueryExecutionStateCancelled)
+			item.err = fmt.Errorf("%v, query stopped", item.err)
+		}
+	}
+}
+
+// printBatchSummary prints one line for each statement run in parallel
+func (s *Session) printBatchSummary() {
+	if s.batch == nil || len(s.batch.finished) == 0 {
+		return
+	}
+	fmt.Println("Summary:")
+	for i, item := range s.batch.finished {
+		state := item.res.State
+		switch {
+		case item.id == "":
+			state = "NOT STARTED"
+		case item.err != nil && state != "FAILED":
+			state = "CANCELLED"
+		}
+		id := item.id
+		if id == "" {
+			id = "-"
+		}
+		query := abbreviate(item.query, jobQueryLength)
+		line := fmt.Sprintf("%d\t%s\t%s\t%s\t%s\t%s", i+1, id, state, item.elapsed.Truncate(100*time.Millisecond), FormatBytes(item.scanned), query)
+		if reason := summaryReason(item.err); reason != "" {
+			line += "\t" + reason
+		}
+		fmt.Println(line)
+	}
+}
+
+// summaryReason explains why a statement in the summary did not succeed
+func summaryReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "cancelled by the user"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	}
+	return err.Error()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "queries.sql")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadFileParallel(t *testing.T) {
+	s, fake := newTestSession(t)
+	file := writeFile(t, "select 1;\nselect 2;\ninsert into t values (1);\ndrop table t;\nselect 3;\n")
+
+	out := captureOutput(t, func() {
+		if err := s.ReadFileParallel(file, 2); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// the insert must not start until the selects before it have been sent
+	var started []string
+	for _, input := range fake.Started {
+		started = append(started, aws.ToString(input.QueryString))
+	}
+	if len(started) != 4 || started[2] != "insert into t values (1)" || started[3] != "select 3" {
+		t.Errorf("queries started in the order %q", started)
+	}
+
+	// results come out in file order
+	first, second := strings.Index(out, "| select 1 |"), strings.Index(out, "| select 2 |")
+	if first < 0 || second < 0 || first > second {
+		t.Errorf("results are out of order:\n%s", out)
+	}
+
+	summary := out[strings.Index(out, "Summary:"):]
+	lines := strings.Split(strings.TrimSpace(summary), "\n")[1:]
+	want := []struct{ state, query string }{
+		{"SUCCEEDED", "select 1"},
+		{"SUCCEEDED", "select 2"},
+		{"SUCCEEDED", "insert into t values (1)"},
+		{"NOT STARTED", "drop table t"},
+		{"SUCCEEDED", "select 3"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("summary has %d lines, want %d:\n%s", len(lines), len(want), summary)
+	}
+	for i, w := range want {
+		fields := strings.Split(lines[i], "\t")
+		if fields[2] != w.state || fields[5] != w.query {
+			t.Errorf("summary line %d is %q, want %s %s", i+1, lines[i], w.state, w.query)
+		}
+	}
+}
+
+func TestParallelSummaryGivesReasons(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.SessionScanLimit = 1024
+	s.scannedTotal = 2048
+	file := writeFile(t, "select 1;\nselect 2;\n")
+
+	out := captureOutput(t, func() {
+		if err := s.ReadFileParallel(file, 2); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(fake.Started) != 0 {
+		t.Errorf("%d queries started over the session limit", len(fake.Started))
+	}
+	summary := out[strings.Index(out, "Summary:"):]
+	lines := strings.Split(strings.TrimSpace(summary), "\n")[1:]
+	if len(lines) != 2 {
+		t.Fatalf("summary has %d lines, want 2:\n%s", len(lines), summary)
+	}
+	for i, line := range lines {
+		fields := strings.Split(line, "\t")
+		if fields[2] != "NOT STARTED" || len(fields) != 7 || !strings.HasPrefix(fields[6], "session scan limit") {
+			t.Errorf("summary line %d is %q, want it not started because of the session limit", i+1, line)
+		}
+	}
+}
+
+func TestParallelStopErrorReported(t *testing.T) {
+	tests := []struct {
+		stopErr error
+		want    string
+	}{
+		{nil, "timed out after 300ms, query stopped"},
+		{errors.New("access denied"), "timed out after 300ms, and could not be stopped: access denied"},
+	}
+	for _, tt := range tests {
+		s, fake := newTestSession(t)
+		fake.AddQuery("select slow", &FakeQuery{
+			States:  []types.QueryExecutionState{types.QueryExecutionStateRunning},
+			StopErr: tt.stopErr,
+		})
+		s.Timeout = 300 * time.Millisecond
+		file := writeFile(t, "select slow;\n")
+
+		out := captureOutput(t, func() {
+			if err := s.ReadFileParallel(file, 2); err != nil {
+				t.Fatal(err)
+			}
+		})
+		summary := out[strings.Index(out, "Summary:"):]
+		if !strings.Contains(out, "Error: query fake-00000001 "+tt.want) || !strings.HasSuffix(strings.TrimSpace(summary), tt.want) {
+			t.Errorf("printed %q, want %q reported", out, tt.want)
+		}
+	}
+}
+
+func TestParallelFileWithoutStats(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.Client = &noStatsAthena{fake}
+	s.ShowStats = true
+	file := writeFile(t, "select 1;\nselect 2;\n")
+	out := captureOutput(t, func() {
+		if err := s.ReadFileParallel(file, 2); err != nil {
+			t.Error(err)
+		}
+	})
+	if lines := strings.Count(out, noStatsLines[false]); lines != 2 {
+		t.Errorf("%d stats lines printed, want 2:\n%s", lines, out)
+	}
+}
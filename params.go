@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryParam is a value bound to a ? placeholder, the value is a SQL literal
+// so strings need to be in single quotes
+type QueryParam struct {
+	Name  string
+	Value string
+}
+
+// ParamFlag collects repeated --param name=value flags
+type ParamFlag []QueryParam
+
+func (p *ParamFlag) String() string {
+	var bits []string
+	for _, param := range *p {
+		bits = append(bits, param.Name+"="+param.Value)
+	}
+	return strings.Join(bits, ",")
+}
+
+func (p *ParamFlag) Set(value string) error {
+	bits := strings.SplitN(value, "=", 2)
+	if len(bits) != 2 || bits[0] == "" {
+		return fmt.Errorf("'%s' should be in the form name=value", value)
+	}
+	*p = append(*p, QueryParam{Name: bits[0], Value: bits[1]})
+	return nil
+}
+
+// SetParam adds a parameter or updates the value of an existing one, the
+// parameters are bound to placeholders in the order they were first set
+func (s *Session) SetParam(name string, value string) {
+	for i, param := range s.Params {
+		if param.Name == name {
+			s.Params[i].Value = value
+			return
+		}
+	}
+	s.Params = append(s.Params, QueryParam{Name: name, Value: value})
+}
+
+// ExecutionParams returns the values to send with a query, one for each ?
+// placeholder it contains
+func (s *Session) ExecutionParams(query string) ([]string, error) {
+	count := CountPlaceholders(query)
+	if count == 0 {
+		return nil, nil
+	}
+	if count > len(s.Params) {
+		return nil, fmt.Errorf("query has %d placeholders but only %d parameters are set", count, len(s.Params))
+	}
+	var values []string
+	for _, param := range s.Params[:count] {
+		values = append(values, param.Value)
+	}
+	return values, nil
+}
+
+// CountPlaceholders counts the ? placeholders in a query which are not inside
+// a string literal, quoted identifier or comment
+func CountPlaceholders(query string) int {
+	count := 0
+	for _, tok := range tokenizeSql(query) {
+		if tok.word == "?" {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Session) listParams() {
+	if len(s.Params) == 0 {
+		fmt.Println("No parameters set.")
+		return
+	}
+	// names are only for setting values, the ? placeholders don't have them
+	fmt.Println("Bound to the ? placeholders of a query in this order:")
+	for i, param := range s.Params {
+		fmt.Printf("%d\t%s\t%s\n", i+1, param.Name, param.Value)
+	}
+}
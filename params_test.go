@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"select 1", 0},
+		{"select * from t where a = ?", 1},
+		{"select * from t where a = ? and b = ?", 2},
+		{"select * from t where a = '?' and b = ?", 1},
+		{"select \"?\", `?` from t where a = ?", 1},
+		{"select * from t where a = 'it''s?' and b = ?", 1},
+		{"select * from t -- is a = ?\nwhere a = ?", 1},
+		{"select * from t /* a = ? */ where a = ?", 1},
+		{"select * from t where a = ? -- ?", 1},
+		{"select * from t where a in (?, ?, ?)", 3},
+	}
+	for _, tt := range tests {
+		if got := CountPlaceholders(tt.query); got != tt.want {
+			t.Errorf("CountPlaceholders(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestExecutionParams(t *testing.T) {
+	s, _ := newTestSession(t)
+	s.SetParam("region", "'eu-west-1'")
+	s.SetParam("year", "2023")
+	s.SetParam("region", "'us-east-1'")
+
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{"select 1", nil, false},
+		{"select * from t where year = ?", []string{"'us-east-1'"}, false},
+		{"select * from t where region = ? and year = ?", []string{"'us-east-1'", "2023"}, false},
+		{"select * from t where a = ? and b = ? and c = ?", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := s.ExecutionParams(tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExecutionParams(%q) error = %v, want error %v", tt.query, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExecutionParams(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"1 2", []string{"1", "2"}, false},
+		{"'a b' 2", []string{"'a b'", "2"}, false},
+		{"  'x'   y ", []string{"'x'", "y"}, false},
+		{"'open", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitArgs(%q) error = %v, want error %v", tt.text, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
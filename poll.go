@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// MinPollInterval is how long to wait before checking on a query the first
+// time, so small queries return almost immediately
+const MinPollInterval time.Duration = 100 * time.Millisecond
+
+// DefaultMaxPollInterval is the longest wait between checks unless it is
+// changed with .poll
+const DefaultMaxPollInterval time.Duration = 5 * time.Second
+
+// Backoff gives the wait between polls, it doubles each time up to Max and
+// has jitter added so many clients do not poll in step
+type Backoff struct {
+	Min  time.Duration
+	Max  time.Duration
+	next time.Duration
+	rand *rand.Rand
+}
+
+// NewBackoff returns a Backoff starting at MinPollInterval, a max of zero
+// uses DefaultMaxPollInterval
+func NewBackoff(max time.Duration) *Backoff {
+	if max <= 0 {
+		max = DefaultMaxPollInterval
+	}
+	return &Backoff{Min: MinPollInterval, Max: max, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Next returns how long to wait before the next poll
+func (b *Backoff) Next() time.Duration {
+	if b.next == 0 {
+		b.next = b.Min
+	}
+	wait := b.next
+	b.next *= 2
+	if b.next > b.Max {
+		b.next = b.Max
+	}
+	if wait > b.Max {
+		wait = b.Max
+	}
+	// wait somewhere between half and all of the interval
+	half := int64(wait / 2)
+	return time.Duration(half + b.rand.Int63n(half+1))
+}
+
+// SetMaxPoll changes the longest wait between checks on a running query
+func (s *Session) SetMaxPoll(value string) error {
+	max, err := time.ParseDuration(value)
+	if err != nil || max < MinPollInterval {
+		return fmt.Errorf("'%s' is not a poll interval, use a duration of at least %s, e.g. 5s", value, MinPollInterval)
+	}
+	s.MaxPollInterval = max
+	return nil
+}
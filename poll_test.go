@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := NewBackoff(time.Second)
+	// the intervals are 100ms, 200ms, 400ms, 800ms and then 1s, each with
+	// jitter taking it down to as little as half
+	ceilings := []time.Duration{100, 200, 400, 800, 1000, 1000, 1000}
+	for i, ceiling := range ceilings {
+		ceiling *= time.Millisecond
+		wait := b.Next()
+		if wait < ceiling/2 || wait > ceiling {
+			t.Errorf("wait %d is %s, want between %s and %s", i+1, wait, ceiling/2, ceiling)
+		}
+	}
+}
+
+func TestBackoffDefaultMax(t *testing.T) {
+	b := NewBackoff(0)
+	if b.Max != DefaultMaxPollInterval {
+		t.Errorf("Max = %s, want %s", b.Max, DefaultMaxPollInterval)
+	}
+	for i := 0; i < 20; i++ {
+		if wait := b.Next(); wait > DefaultMaxPollInterval {
+			t.Fatalf("wait %s is over the maximum", wait)
+		}
+	}
+}
+
+func TestSetMaxPoll(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"10s", 10 * time.Second, false},
+		{"100ms", 100 * time.Millisecond, false},
+		{"50ms", DefaultMaxPollInterval, true},
+		{"soon", DefaultMaxPollInterval, true},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		s.MaxPollInterval = DefaultMaxPollInterval
+		err := s.SetMaxPoll(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetMaxPoll(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+		}
+		if s.MaxPollInterval != tt.want {
+			t.Errorf("SetMaxPoll(%q) set %s, want %s", tt.value, s.MaxPollInterval, tt.want)
+		}
+	}
+}
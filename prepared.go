@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// SplitArgs splits command arguments on spaces, keeping single-quoted SQL
+// string literals (which may contain spaces) together with their quotes
+func SplitArgs(text string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inQuote := false
+	for _, c := range text {
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+			current.WriteRune(c)
+		case c == ' ' && !inQuote:
+			if current.Len() > 0 {
+				args = append(args, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated string literal")
+	}
+	if current.Len() > 0 {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// Prepare creates a prepared statement in the current workgroup
+func (s *Session) Prepare(name string, query string) error {
+	if s.ReadOnly {
+		return errors.New("prepared statements cannot be created in read-only mode")
+	}
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	// it has to be allowed to run now for it to be allowed to run later
+	policyErr := s.CheckStatement(query)
+	if policyErr != nil {
+		return policyErr
+	}
+	err := CreatePreparedStatement(name, query, s.WorkGroup, s.Client, s.Ctx)
+	if err != nil {
+		return err
+	}
+	s.prepared = nil
+	fmt.Printf("Prepared statement '%s' created.\n", name)
+	return nil
+}
+
+// ShowPrepared lists the prepared statements in the current workgroup, or
+// prints the SQL of one of them if a name is given
+func (s *Session) ShowPrepared(name string) error {
+	if name != "" {
+		stmt, err := GetPreparedStatement(name, s.WorkGroup, s.Client, s.Ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s;\n", strings.TrimRight(*stmt.QueryStatement, "\r\n;"))
+		return nil
+	}
+	statements, err := ListPreparedStatements(s.WorkGroup, s.Client, s.Ctx)
+	if err != nil {
+		return err
+	}
+	if len(statements) == 0 {
+		fmt.Printf("No prepared statements in workgroup %s.\n", s.WorkGroup)
+		return nil
+	}
+	for _, stmt := range statements {
+		modified := ""
+		if stmt.LastModifiedTime != nil {
+			modified = stmt.LastModifiedTime.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%s\t%s\n", *stmt.StatementName, modified)
+	}
+	return nil
+}
+
+// ExecPrepared runs a prepared statement like any other statement, the
+// arguments are bound to its ? placeholders as execution parameters
+func (s *Session) ExecPrepared(name string, args []string) {
+	s.runStatement("EXECUTE "+name, args)
+}
+
+// preparedCategory classifies the query a prepared statement runs, Ctrl-C
+// stops it waiting for Athena
+func (s *Session) preparedCategory(name string) (StatementCategory, error) {
+	ctx := s.startQuery()
+	defer s.finishQuery()
+	stmt, err := GetPreparedStatement(name, s.WorkGroup, s.Client, ctx)
+	if err != nil {
+		return CategoryOther, err
+	}
+	return ClassifyStatement(aws.ToString(stmt.QueryStatement)), nil
+}
+
+// Unprepare deletes a prepared statement from the current workgroup
+func (s *Session) Unprepare(name string) error {
+	if s.ReadOnly {
+		return errors.New("prepared statements cannot be deleted in read-only mode")
+	}
+	err := DeletePreparedStatement(name, s.WorkGroup, s.Client, s.Ctx)
+	if err != nil {
+		return err
+	}
+	s.prepared = nil
+	fmt.Printf("Prepared statement '%s' deleted.\n", name)
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestPreparedStatementCommands(t *testing.T) {
+	s, fake := newTestSession(t)
+
+	steps := []struct {
+		line string
+		want string
+	}{
+		{".prepare byregion select * from sales where region = ?", "Prepared statement 'byregion' created."},
+		{".prepared", "byregion\t"},
+		{".prepared byregion", "select * from sales where region = ?;"},
+		{".exec byregion 'north east'", "Query id: fake-00000001"},
+		{".unprepare byregion", "Prepared statement 'byregion' deleted."},
+		{".prepared", "No prepared statements in workgroup primary."},
+	}
+	for _, step := range steps {
+		out := captureOutput(t, func() {
+			s.SendLine(step.line)
+		})
+		if !strings.Contains(out, step.want) {
+			t.Errorf("%s printed %q, want %q", step.line, out, step.want)
+		}
+	}
+
+	if len(fake.Started) != 1 {
+		t.Fatalf("%d queries started, want 1", len(fake.Started))
+	}
+	started := fake.Started[0]
+	if got := aws.ToString(started.QueryString); got != "EXECUTE byregion" {
+		t.Errorf("ran %q, want EXECUTE byregion", got)
+	}
+	if got := started.ExecutionParameters; len(got) != 1 || got[0] != "'north east'" {
+		t.Errorf("bound %q, want 'north east'", got)
+	}
+}
+
+func TestExecInParallelFile(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.DdlEnabled = true
+	captureOutput(t, func() {
+		s.SendLine(".prepare reader select 1")
+		s.SendLine(".prepare writer insert into t values (1)")
+	})
+
+	// a prepared read waits in the batch, a prepared write runs on its own
+	s.batch = &statementBatch{parallel: 2}
+	captureOutput(t, func() {
+		s.SendLine(".exec reader")
+	})
+	if len(fake.Started) != 0 || len(s.batch.pending) != 1 {
+		t.Errorf("%d queries started and %d queued, want the read queued", len(fake.Started), len(s.batch.pending))
+	}
+	captureOutput(t, func() {
+		s.SendLine(".exec writer")
+	})
+	if len(s.batch.pending) != 0 || len(s.batch.finished) != 2 {
+		t.Fatalf("%d queued and %d finished, want both run", len(s.batch.pending), len(s.batch.finished))
+	}
+	for i, want := range []string{"EXECUTE reader", "EXECUTE writer"} {
+		if got := aws.ToString(fake.Started[i].QueryString); got != want {
+			t.Errorf("query %d was %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestExecBlockedInReadOnlyMode(t *testing.T) {
+	s, fake := newTestSession(t)
+	captureOutput(t, func() {
+		s.SendLine(".prepare writer insert into t values (1)")
+	})
+	s.ReadOnly = true
+	s.batch = &statementBatch{parallel: 2}
+
+	out := captureOutput(t, func() {
+		s.SendLine(".exec writer")
+	})
+	if !strings.Contains(out, "prepared statement 'writer' is DML, DML statements are blocked in read-only mode") {
+		t.Errorf("printed %q, want the statement blocked", out)
+	}
+	if len(fake.Started) != 0 {
+		t.Errorf("%d queries started, want 0", len(fake.Started))
+	}
+	if len(s.batch.finished) != 1 || s.batch.finished[0].err == nil {
+		t.Errorf("blocked statement is not in the summary")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// IsTerminal reports if a file is an interactive terminal rather than a pipe
+// or a regular file
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// ProgressLine is a status line which is rewritten in place while a query
+// runs, it does nothing when it is not enabled
+type ProgressLine struct {
+	out     io.Writer
+	enabled bool
+	start   time.Time
+	width   int // length of the last line written, so it can be cleared
+}
+
+func NewProgressLine(out io.Writer, enabled bool) *ProgressLine {
+	return &ProgressLine{out: out, enabled: enabled, start: time.Now()}
+}
+
+// Update shows the state of the query, how long it has been running and how
+// much it has scanned so far
+func (p *ProgressLine) Update(exec *types.QueryExecution, pricePerTB float64) {
+	if !p.enabled {
+		return
+	}
+	var scanned int64
+	if exec.Statistics != nil {
+		scanned = aws.ToInt64(exec.Statistics.DataScannedInBytes)
+	}
+	var state types.QueryExecutionState
+	if exec.Status != nil {
+		state = exec.Status.State
+	}
+	elapsed := time.Since(p.start).Truncate(100 * time.Millisecond)
+	text := fmt.Sprintf("%s  %s  %s scanned  ~%s", state, elapsed, FormatBytes(scanned), FormatCost(EstimateCost(scanned, pricePerTB)))
+	padding := ""
+	if len(text) < p.width {
+		padding = strings.Repeat(" ", p.width-len(text))
+	}
+	fmt.Fprintf(p.out, "\r%s%s", text, padding)
+	p.width = len(text)
+}
+
+// Clear removes the status line so the next output starts on a clean line
+func (p *ProgressLine) Clear() {
+	if !p.enabled || p.width == 0 {
+		return
+	}
+	fmt.Fprintf(p.out, "\r%s\r", strings.Repeat(" ", p.width))
+	p.width = 0
+}
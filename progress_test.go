@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestProgressLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   types.QueryExecutionState
+		scanned *int64
+		want    string
+	}{
+		// even a query which has scanned nothing is charged for 10MB
+		{"queued", types.QueryExecutionStateQueued, nil, "QUEUED  1.5s  0 B scanned  ~$0.000048"},
+		{"running", types.QueryExecutionStateRunning, aws.Int64(0), "RUNNING  1.5s  0 B scanned  ~$0.000048"},
+		{"scanning", types.QueryExecutionStateRunning, aws.Int64(3 << 30), "RUNNING  1.5s  3.00 GB scanned  ~$0.01"},
+		{"over a terabyte", types.QueryExecutionStateRunning, aws.Int64(2 << 40), "RUNNING  1.5s  2.00 TB scanned  ~$10.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := NewProgressLine(&out, true)
+			p.start = time.Now().Add(-1500 * time.Millisecond)
+			exec := &types.QueryExecution{Status: &types.QueryExecutionStatus{State: tt.state}}
+			if tt.scanned != nil {
+				exec.Statistics = &types.QueryExecutionStatistics{DataScannedInBytes: tt.scanned}
+			}
+			p.Update(exec, 5)
+			if got := out.String(); got != "\r"+tt.want {
+				t.Errorf("wrote %q, want %q", got, "\r"+tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressLineClearsLongerText(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgressLine(&out, true)
+	p.Update(&types.QueryExecution{Status: &types.QueryExecutionStatus{State: types.QueryExecutionStateRunning}}, 5)
+	width := out.Len() - 1
+	out.Reset()
+
+	// a shorter line is padded so nothing is left of the longer one
+	p.Update(&types.QueryExecution{Status: &types.QueryExecutionStatus{State: types.QueryExecutionStateQueued}}, 5)
+	if got := out.Len() - 1; got != width {
+		t.Errorf("second line is %d characters, want %d", got, width)
+	}
+	text := strings.TrimSpace(out.String())
+	out.Reset()
+	p.Clear()
+	if got, want := out.String(), "\r"+strings.Repeat(" ", len(text))+"\r"; got != want {
+		t.Errorf("Clear wrote %q, want %q", got, want)
+	}
+}
+
+func TestProgressLineDisabled(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgressLine(&out, false)
+	p.Update(&types.QueryExecution{Status: &types.QueryExecutionStatus{State: types.QueryExecutionStateRunning}}, 5)
+	p.Clear()
+	if out.Len() != 0 {
+		t.Errorf("disabled progress line wrote %q", out.String())
+	}
+}
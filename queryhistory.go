@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// DefaultHistoryCount is how many queries .history remote shows by default
+const DefaultHistoryCount int = 20
+
+// historySearchLimit is how many queries are looked at when filtering, so a
+// filter which matches nothing doesn't page through the whole workgroup,
+// without a filter every query asked for is shown
+const historySearchLimit int = 1000
+
+// historyFilter picks which queries from the workgroup history are shown
+type historyFilter struct {
+	count int
+	state string
+	text  string
+}
+
+// parseHistoryArgs reads the arguments of .history remote, [n] [--state STATE] [--text TEXT]
+func parseHistoryArgs(args []string) (historyFilter, error) {
+	filter := historyFilter{count: DefaultHistoryCount}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--state", "--text":
+			if i+1 >= len(args) {
+				return filter, fmt.Errorf("%s expects a value", args[i])
+			}
+			if args[i] == "--state" {
+				filter.state = strings.ToUpper(args[i+1])
+			} else {
+				filter.text = strings.ToLower(args[i+1])
+			}
+			i++
+		default:
+			count, err := strconv.Atoi(args[i])
+			if err != nil || count < 1 {
+				return filter, fmt.Errorf("'%s' is not a number of queries to show", args[i])
+			}
+			filter.count = count
+		}
+	}
+	return filter, nil
+}
+
+func (f historyFilter) matches(exec types.QueryExecution) bool {
+	if f.state != "" && (exec.Status == nil || string(exec.Status.State) != f.state) {
+		return false
+	}
+	if f.text != "" && !strings.Contains(strings.ToLower(aws.ToString(exec.Query)), f.text) {
+		return false
+	}
+	return true
+}
+
+// ShowRemoteHistory lists the queries run in the current workgroup, by anyone
+func (s *Session) ShowRemoteHistory(args []string) error {
+	filter, err := parseHistoryArgs(args)
+	if err != nil {
+		return err
+	}
+
+	historyCtx := s.startQuery()
+	defer s.finishQuery()
+
+	filtered := filter.state != "" || filter.text != ""
+	shown, seen, limited := 0, 0, false
+	err = ListQueryHistory(s.WorkGroup, func(exec types.QueryExecution) bool {
+		seen++
+		if filter.matches(exec) {
+			printHistoryLine(exec)
+			shown++
+		}
+		limited = filtered && seen >= historySearchLimit && shown < filter.count
+		return shown < filter.count && !limited
+	}, s.Client, historyCtx)
+	if historyCtx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if limited {
+		fmt.Printf("Stopped searching after the latest %d queries, %d matched\n", historySearchLimit, shown)
+	} else if shown == 0 {
+		fmt.Println("No matching queries found.")
+	}
+	return nil
+}
+
+func printHistoryLine(exec types.QueryExecution) {
+	submitted, state := "-", ""
+	if exec.Status != nil {
+		state = string(exec.Status.State)
+		if exec.Status.SubmissionDateTime != nil {
+			submitted = exec.Status.SubmissionDateTime.Local().Format("2006-01-02 15:04:05")
+		}
+	}
+	var scanned int64
+	var runtime *int64
+	if exec.Statistics != nil {
+		scanned = aws.ToInt64(exec.Statistics.DataScannedInBytes)
+		runtime = exec.Statistics.EngineExecutionTimeInMillis
+	}
+	query := abbreviate(aws.ToString(exec.Query), jobQueryLength)
+	fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n", aws.ToString(exec.QueryExecutionId), submitted, state, FormatBytes(scanned), formatMillis(runtime), query)
+}
+
+// Rerun runs the SQL of an earlier query again, with the same parameters,
+// in the catalog and database it first ran in
+func (s *Session) Rerun(execId string) error {
+	exec, err := GetQueryExecution(execId, s.Client, s.Ctx)
+	if err != nil {
+		return err
+	}
+	query := strings.TrimSpace(aws.ToString(exec.Query))
+	if query == "" {
+		return errors.New("the query has no SQL to run")
+	}
+	fmt.Printf("Rerunning: %s\n", strings.Join(strings.Fields(query), " "))
+
+	policyErr := s.CheckStatement(query)
+	if policyErr != nil {
+		return policyErr
+	}
+
+	// the same SQL could read different tables in another database
+	catalog, database := s.Catalog, s.Database
+	if qec := exec.QueryExecutionContext; qec != nil {
+		if aws.ToString(qec.Catalog) != "" {
+			catalog = aws.ToString(qec.Catalog)
+		}
+		if aws.ToString(qec.Database) != "" {
+			database = aws.ToString(qec.Database)
+		}
+	}
+	if catalog != s.Catalog || database != s.Database {
+		fmt.Printf("Running in %s.%s where it first ran, not %s.%s\n", catalog, database, s.Catalog, s.Database)
+		defer func(catalog string, database string) {
+			s.Catalog, s.Database = catalog, database
+		}(s.Catalog, s.Database)
+		s.Catalog, s.Database = catalog, database
+	}
+	s.RunQuery(query, exec.ExecutionParameters)
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+)
+
+func TestRerunUsesOriginalDatabase(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.SendLine(".use federated.sales")
+	s.SendLine("select * from orders;")
+	s.SendLine(".use AwsDataCatalog.db")
+
+	if err := s.Rerun("fake-00000001"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Started) != 2 {
+		t.Fatalf("%d queries started, want 2", len(fake.Started))
+	}
+	qec := fake.Started[1].QueryExecutionContext
+	if aws.ToString(qec.Catalog) != "federated" || aws.ToString(qec.Database) != "sales" {
+		t.Errorf("rerun in %s.%s, want federated.sales", aws.ToString(qec.Catalog), aws.ToString(qec.Database))
+	}
+	if s.Catalog != "AwsDataCatalog" || s.Database != "db" {
+		t.Errorf("session left using %s.%s", s.Catalog, s.Database)
+	}
+}
+
+func TestRemoteHistoryLimit(t *testing.T) {
+	s, fake := newTestSession(t)
+	total := historySearchLimit + 50
+	for i := 0; i < total; i++ {
+		_, err := fake.StartQueryExecution(s.Ctx, &athena.StartQueryExecutionInput{
+			QueryString: aws.String(fmt.Sprintf("select %d", i)),
+			WorkGroup:   aws.String(s.WorkGroup),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args      []string
+		lines     int
+		truncated bool
+	}{
+		{[]string{"5"}, 5, false},
+		{[]string{fmt.Sprint(total + 10)}, total, false},
+		{[]string{"5", "--text", "nothing"}, 0, true},
+		{[]string{"1", "--text", "select 1049"}, 1, false},
+		{[]string{"5", "--text", "select 1049"}, 1, true},
+	}
+	for _, tt := range tests {
+		var err error
+		out := captureOutput(t, func() {
+			err = s.ShowRemoteHistory(tt.args)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Count(out, "fake-")
+		truncated := strings.Contains(out, "Stopped searching")
+		if lines != tt.lines || truncated != tt.truncated {
+			t.Errorf("%v showed %d queries (truncated %v), want %d (truncated %v)", tt.args, lines, truncated, tt.lines, tt.truncated)
+		}
+	}
+}
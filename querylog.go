@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// logSearchResults is how many matches .log search shows, the newest ones
+const logSearchResults int = 20
+
+// queryLogMu stops background queries writing to the log at the same time
+var queryLogMu sync.Mutex
+
+// QueryLogEntry is a line of the query log, one is written for every
+// statement the tool runs
+type QueryLogEntry struct {
+	Time          time.Time `json:"time"`
+	WorkGroup     string    `json:"workgroup"`
+	Catalog       string    `json:"catalog,omitempty"`
+	Database      string    `json:"database"`
+	QueryId       string    `json:"query_id,omitempty"`
+	State         string    `json:"state"`
+	BytesScanned  int64     `json:"bytes_scanned"`
+	RuntimeMillis int64     `json:"runtime_ms"`
+	Error         string    `json:"error,omitempty"`
+	Query         string    `json:"query"`
+}
+
+// NewQueryLogEntry describes how a query ended, id is empty if it could not be started
+func NewQueryLogEntry(workGroup string, catalog string, database string, id string, query string, res QuerySummary, err error) QueryLogEntry {
+	entry := QueryLogEntry{
+		Time:      time.Now(),
+		WorkGroup: workGroup,
+		Catalog:   catalog,
+		Database:  database,
+		QueryId:   id,
+		State:     res.State,
+		Query:     query,
+	}
+	if res.Stats != nil {
+		entry.BytesScanned = aws.ToInt64(res.Stats.DataScannedInBytes)
+		entry.RuntimeMillis = aws.ToInt64(res.Stats.EngineExecutionTimeInMillis)
+	}
+
+	var limitErr *ScanLimitError
+	switch {
+	case err == nil:
+	case id == "":
+		entry.State = "NOT STARTED"
+		entry.Error = err.Error()
+	case errors.Is(err, context.Canceled):
+		entry.State = "CANCELLED"
+		entry.Error = "cancelled by the user"
+	case errors.Is(err, context.DeadlineExceeded):
+		entry.State = "CANCELLED"
+		entry.Error = "timed out"
+	case errors.As(err, &limitErr):
+		entry.State = "CANCELLED"
+		entry.Error = err.Error()
+	default:
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// LogQuery appends an entry to the query log, if there is one
+func (s *Session) LogQuery(entry QueryLogEntry) {
+	if s.QueryLog == "" {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err == nil {
+		queryLogMu.Lock()
+		defer queryLogMu.Unlock()
+		var f *os.File
+		f, err = os.OpenFile(s.QueryLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.Write(append(data, '\n'))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err != nil {
+		fmt.Println("Error: could not write to the query log", err)
+	}
+}
+
+// readQueryLog returns every entry in the query log, oldest first
+func (s *Session) readQueryLog() ([]QueryLogEntry, error) {
+	if s.QueryLog == "" {
+		return nil, errors.New("the query log is not enabled")
+	}
+	f, err := os.Open(s.QueryLog)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []QueryLogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry QueryLogEntry
+		// a damaged line is kept so the numbers still match the file
+		json.Unmarshal(scanner.Bytes(), &entry)
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// SearchQueryLog lists the latest entries whose SQL, id or error contain text
+func (s *Session) SearchQueryLog(text string) error {
+	entries, err := s.readQueryLog()
+	if err != nil {
+		return err
+	}
+	text = strings.ToLower(text)
+	var matches []int
+	for i, entry := range entries {
+		if strings.Contains(strings.ToLower(entry.Query), text) ||
+			strings.Contains(strings.ToLower(entry.QueryId), text) ||
+			strings.Contains(strings.ToLower(entry.Error), text) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println("No matching queries found.")
+		return nil
+	}
+	if len(matches) > logSearchResults {
+		fmt.Printf("Showing the latest %d of %d matches\n", logSearchResults, len(matches))
+		matches = matches[len(matches)-logSearchResults:]
+	}
+	for _, i := range matches {
+		entry := entries[i]
+		query := abbreviate(entry.Query, jobQueryLength)
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", i+1, entry.Time.Local().Format("2006-01-02 15:04:05"), entry.State, entry.QueryId, query)
+	}
+	return nil
+}
+
+// ShowQueryLog prints everything recorded in one entry of the query log
+func (s *Session) ShowQueryLog(num string) error {
+	entries, err := s.readQueryLog()
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 1 || n > len(entries) {
+		return fmt.Errorf("there is no log entry '%s', use '.log search' to find one", num)
+	}
+	entry := entries[n-1]
+	fmt.Printf("Time:          %s\n", entry.Time.Local().Format(time.RFC3339))
+	fmt.Printf("Workgroup:     %s\n", entry.WorkGroup)
+	if entry.Catalog != "" {
+		fmt.Printf("Catalog:       %s\n", entry.Catalog)
+	}
+	fmt.Printf("Database:      %s\n", entry.Database)
+	fmt.Printf("Query id:      %s\n", entry.QueryId)
+	fmt.Printf("State:         %s\n", entry.State)
+	fmt.Printf("Data scanned:  %s\n", FormatBytes(entry.BytesScanned))
+	fmt.Printf("Runtime:       %s\n", formatMillis(&entry.RuntimeMillis))
+	if entry.Error != "" {
+		fmt.Printf("Error:         %s\n", entry.Error)
+	}
+	fmt.Println("SQL:")
+	for _, line := range strings.Split(entry.Query, "\n") {
+		fmt.Printf("  %s\n", line)
+	}
+	return nil
+}
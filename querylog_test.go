@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestSchemaQueriesAreLogged(t *testing.T) {
+	s, fake := newTestSession(t)
+	s.QueryLog = filepath.Join(t.TempDir(), "queries.jsonl")
+	fake.AddQuery("select table_name, view_definition from information_schema.views where table_schema='db'", &FakeQuery{
+		Pages: []types.ResultSet{FakeResultSet([]string{"table_name", "view_definition"})},
+	})
+	fake.AddQuery("select table_name from information_schema.tables where table_schema='db'", &FakeQuery{
+		Pages: []types.ResultSet{FakeResultSet([]string{"table_name"}, []string{"t"})},
+	})
+
+	s.SendLine(".schema")
+
+	entries, err := s.readQueryLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("%d queries logged, want 3", len(entries))
+	}
+	if got := entries[2].Query; got != "show create table t" {
+		t.Errorf("last query logged is %q", got)
+	}
+}
+
+func TestQueryLogRecordsCatalog(t *testing.T) {
+	s, _ := newTestSession(t)
+	s.QueryLog = filepath.Join(t.TempDir(), "queries.jsonl")
+	s.SendLine(".use federated.sales")
+	s.SendLine("select 1;")
+
+	entries, err := s.readQueryLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d queries logged, want 1", len(entries))
+	}
+	if entries[0].Catalog != "federated" || entries[0].Database != "sales" {
+		t.Errorf("logged %s.%s, want federated.sales", entries[0].Catalog, entries[0].Database)
+	}
+}
+
+func TestNewQueryLogEntry(t *testing.T) {
+	tests := []struct {
+		id        string
+		state     string
+		err       error
+		wantState string
+		wantError string
+	}{
+		{"q1", "SUCCEEDED", nil, "SUCCEEDED", ""},
+		{"", "", errors.New("throttled"), "NOT STARTED", "throttled"},
+		{"q1", "RUNNING", context.Canceled, "CANCELLED", "cancelled by the user"},
+		{"q1", "RUNNING", fmt.Errorf("waiting: %w", context.DeadlineExceeded), "CANCELLED", "timed out"},
+		{"q1", "RUNNING", &ScanLimitError{Scanned: 2048, Limit: 1024}, "CANCELLED", "query has scanned"},
+		{"q1", "FAILED", errors.New("table not found"), "FAILED", "table not found"},
+	}
+	for _, tt := range tests {
+		entry := NewQueryLogEntry("primary", "AwsDataCatalog", "db", tt.id, "select 1", QuerySummary{State: tt.state}, tt.err)
+		if entry.State != tt.wantState {
+			t.Errorf("state for %v = %q, want %q", tt.err, entry.State, tt.wantState)
+		}
+		if !strings.HasPrefix(entry.Error, tt.wantError) || (tt.wantError == "") != (entry.Error == "") {
+			t.Errorf("error for %v = %q, want %q", tt.err, entry.Error, tt.wantError)
+		}
+	}
+}
+
+func TestSearchQueryLog(t *testing.T) {
+	s, _ := newTestSession(t)
+	s.QueryLog = filepath.Join(t.TempDir(), "queries.jsonl")
+	for i := 0; i < logSearchResults+5; i++ {
+		s.LogQuery(NewQueryLogEntry("primary", "", "db", fmt.Sprintf("q%d", i), fmt.Sprintf("select %d from orders", i), QuerySummary{State: "SUCCEEDED"}, nil))
+	}
+	s.LogQuery(NewQueryLogEntry("primary", "", "db", "q-last", "select * from customers", QuerySummary{State: "FAILED"}, errors.New("access denied")))
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"CUSTOMERS", []string{"26\t", "q-last"}},
+		{"denied", []string{"q-last"}},
+		{"orders", []string{"Showing the latest 20 of 25 matches", "6\t", "25\t"}},
+		{"nothing", []string{"No matching queries found."}},
+	}
+	for _, tt := range tests {
+		out := captureOutput(t, func() {
+			if err := s.SearchQueryLog(tt.text); err != nil {
+				t.Error(err)
+			}
+		})
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("search for %q printed %q, want %q in it", tt.text, out, want)
+			}
+		}
+	}
+
+	out := captureOutput(t, func() {
+		if err := s.ShowQueryLog("26"); err != nil {
+			t.Error(err)
+		}
+	})
+	if !strings.Contains(out, "Error:         access denied") || !strings.Contains(out, "  select * from customers") {
+		t.Errorf("ShowQueryLog printed %q", out)
+	}
+	if err := s.ShowQueryLog("27"); err == nil {
+		t.Error("ShowQueryLog showed an entry past the end of the log")
+	}
+}
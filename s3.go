@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3PageRows is the number of rows read from an S3 result file before they
+// are passed on to the output as a page
+const S3PageRows = 1000
+
+// S3API is the subset of the S3 client used by this tool, it is satisfied by
+// *s3.Client and by FakeS3
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+var _ S3API = (*s3.Client)(nil)
+
+// ParseS3Uri splits an s3://bucket/key location into its bucket and key
+func ParseS3Uri(uri string) (string, string, error) {
+	if !strings.HasPrefix(uri, "s3://") {
+		return "", "", fmt.Errorf("'%s' is not an s3:// location", uri)
+	}
+	bits := strings.SplitN(strings.TrimPrefix(uri, "s3://"), "/", 2)
+	if len(bits) != 2 || bits[0] == "" || bits[1] == "" {
+		return "", "", fmt.Errorf("'%s' does not include a bucket and key", uri)
+	}
+	return bits[0], bits[1], nil
+}
+
+// StreamS3Results reads the result CSV Athena wrote to the output location and
+// passes it to handlePage in pages of S3PageRows rows, the first page starts
+// with the column names row in the same way as StreamQueryResults
+func StreamS3Results(execId string, outputLocation string, client AthenaAPI, s3Client S3API, ctx context.Context, handlePage func(rows []types.Row, columns []types.ColumnInfo) error) error {
+	bucket, key, err := ParseS3Uri(outputLocation)
+	if err != nil {
+		return err
+	}
+
+	// the column metadata is only available from Athena, so ask for it along
+	// with a single row
+	meta, err := client.GetQueryResults(ctx, &athena.GetQueryResultsInput{
+		QueryExecutionId: aws.String(execId),
+		MaxResults:       aws.Int32(1),
+	})
+	if err != nil {
+		return err
+	}
+	columns := meta.ResultSet.ResultSetMetadata.ColumnInfo
+
+	obj, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+
+	reader := csv.NewReader(obj.Body)
+	reader.FieldsPerRecord = -1
+
+	var rows []types.Row
+	written := false
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var row types.Row
+		for _, val := range record {
+			row.Data = append(row.Data, types.Datum{VarCharValue: aws.String(val)})
+		}
+		rows = append(rows, row)
+		if len(rows) >= S3PageRows {
+			err = handlePage(rows, columns)
+			if err != nil {
+				return err
+			}
+			rows = nil
+			written = true
+		}
+	}
+	if len(rows) > 0 || !written {
+		return handlePage(rows, columns)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// countingS3 counts the objects read
+type countingS3 struct {
+	*FakeS3
+	gets int
+}
+
+func (f *countingS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.gets++
+	return f.FakeS3.GetObject(ctx, params, optFns...)
+}
+
+func TestS3FetchOnlyForSelect(t *testing.T) {
+	tests := []struct {
+		query  string
+		stmt   types.StatementType
+		fromS3 bool
+	}{
+		{"select 1", types.StatementTypeDml, true},
+		{"with a as (select 1) select * from a", types.StatementTypeDml, true},
+		{"insert into t values (1)", types.StatementTypeDml, false},
+		{"create table t2 as select * from t", types.StatementTypeDml, false},
+		{"unload (select 1) to 's3://b/' with (format = 'json')", types.StatementTypeDml, false},
+		{"show tables", types.StatementTypeUtility, false},
+	}
+	for _, tt := range tests {
+		s, fake := newTestSession(t)
+		fake.S3 = NewFakeS3()
+		counting := &countingS3{FakeS3: fake.S3}
+		s.S3 = counting
+		s.FetchMode = "s3"
+		s.DdlEnabled = true
+		fake.AddQuery(tt.query, &FakeQuery{
+			StatementType: tt.stmt,
+			Pages:         []types.ResultSet{FakeResultSet([]string{"a"}, []string{"1"})},
+		})
+		captureOutput(t, func() {
+			s.SendLine(tt.query + ";")
+		})
+		if fromS3 := counting.gets > 0; fromS3 != tt.fromS3 {
+			t.Errorf("%q fetched from S3 %v, want %v", tt.query, fromS3, tt.fromS3)
+		}
+	}
+}
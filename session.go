@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session holds the state of one interactive shell, several sessions can run
+// independently in the same process
+type Session struct {
+	Ctx    context.Context
+	Client AthenaAPI
+	S3     S3API
+
+	WorkGroup  string
+	Catalog    string
+	Database   string
+	OutputMode string
+	JsonMode   string
+	DdlEnabled bool
+	// ReadOnly blocks every statement which is not read-only, apart from the
+	// categories in ReadOnlyAllow
+	ReadOnly       bool
+	ReadOnlyAllow  map[StatementCategory]bool
+	readOnlyLocked bool // set by --read-only so it can't be turned off
+	ShowStats      bool
+	FullStats      bool // show all the statistics rather than the summary line
+	ShowProgress   bool // show a status line while queries run
+	ShowHeader     bool
+	OutputFile     string
+	FetchMode      string
+	Params         []QueryParam
+	// ScanLimit stops a query once it has scanned this many bytes and
+	// SessionScanLimit refuses to start queries once the session has, zero
+	// means no limit
+	ScanLimit        int64
+	SessionScanLimit int64
+	PricePerTB       float64
+	MaxPollInterval  time.Duration
+	// Timeout stops queries which run for longer than it, zero means no limit
+	Timeout time.Duration
+	// QueryLog is the file each query run is recorded in, empty for none
+	QueryLog string
+
+	splitter     StatementSplitter
+	quit         bool
+	cancelled    bool // set when the last query was cancelled with Ctrl-C
+	metadata     *metadataCache
+	prepared     *preparedCache
+	scannedTotal int64 // bytes scanned by all the queries run so far
+	costs        []queryCost
+	jobs         []*Job          // queries run in the background
+	batch        *statementBatch // set while a file is run in parallel
+
+	mu          sync.Mutex
+	cancelQuery context.CancelFunc
+}
+
+func NewSession(ctx context.Context, client AthenaAPI, workGroup string, database string) *Session {
+	return &Session{
+		Ctx:             ctx,
+		Client:          client,
+		WorkGroup:       workGroup,
+		Catalog:         DefaultCatalog,
+		Database:        database,
+		OutputMode:      "ascii",
+		JsonMode:        "array",
+		DdlEnabled:      false,
+		ReadOnlyAllow:   map[StatementCategory]bool{},
+		ShowStats:       false,
+		ShowHeader:      true,
+		OutputFile:      "",
+		FetchMode:       "api",
+		PricePerTB:      DefaultPricePerTB,
+		MaxPollInterval: DefaultMaxPollInterval,
+	}
+}
+
+// Reset discards any partially entered query
+func (s *Session) Reset() {
+	s.splitter.Reset()
+}
+
+// Prompt returns the prompt to show for the next line of input, it shows the
+// catalog and database queries will run in
+func (s *Session) Prompt() string {
+	prompt := s.Catalog + "." + s.Database + "> "
+	if !s.splitter.Pending() {
+		// new line
+		return prompt
+	}
+	// continuation of previous line, lined up with the first
+	indent := len(prompt) - len("...> ")
+	if indent < 0 {
+		indent = 0
+	}
+	return strings.Repeat(" ", indent) + "...> "
+}
+
+// Pending reports if a query has been started but not yet ended with ';'
+func (s *Session) Pending() bool {
+	return s.splitter.Pending()
+}
+
+// InLiteral reports if the input so far ends inside a string, quoted
+// identifier or block comment, where a blank line is part of the query
+func (s *Session) InLiteral() bool {
+	return s.splitter.InLiteral()
+}
+
+// Quit reports if the user has asked to leave the shell
+func (s *Session) Quit() bool {
+	return s.quit
+}
+
+// startQuery returns a context for running a query which is cancelled by
+// Interrupt or when the timeout passes
+func (s *Session) startQuery() context.Context {
+	return s.startQueryWithin(s.Timeout)
+}
+
+// startQueryWithin is startQuery with a different timeout, zero for none
+func (s *Session) startQueryWithin(timeout time.Duration) context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := withTimeout(s.Ctx, timeout)
+	s.cancelQuery = cancel
+	s.cancelled = false
+	return ctx
+}
+
+// withTimeout returns a context which is cancelled after timeout, or only
+// when cancel is called if timeout is zero
+func withTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(parent, timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// finishQuery releases the context created by startQuery
+func (s *Session) finishQuery() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelQuery != nil {
+		s.cancelQuery()
+		s.cancelQuery = nil
+	}
+}
+
+// Interrupt cancels the running query, it returns false if there was no query
+// running, it is safe to call from another goroutine
+func (s *Session) Interrupt() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelQuery == nil {
+		return false
+	}
+	s.cancelQuery()
+	s.cancelQuery = nil
+	return true
+}
+
+// SetTimeout changes how long queries can run for, "off" removes the limit
+func (s *Session) SetTimeout(value string) error {
+	if value == "off" {
+		s.Timeout = 0
+		return nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("'%s' is not a timeout, use a duration such as 90s or 5m, or 'off'", value)
+	}
+	s.Timeout = timeout
+	return nil
+}
+
+// UseDatabase changes the database queries run in, "catalog.database"
+// changes the catalog as well
+func (s *Session) UseDatabase(name string) error {
+	bits := strings.SplitN(name, ".", 2)
+	for _, bit := range bits {
+		if bit == "" {
+			return fmt.Errorf("'%s' should be a database or catalog.database", name)
+		}
+	}
+	if len(bits) == 2 {
+		s.Catalog = bits[0]
+	}
+	s.Database = bits[len(bits)-1]
+	return nil
+}
+
+// SetCatalog changes the data catalog queries run in
+func (s *Session) SetCatalog(name string) error {
+	if name == "" {
+		return errors.New(".catalog expects a data catalog name as an argument")
+	}
+	s.Catalog = name
+	return nil
+}
+
+// SetWorkGroup changes the workgroup queries run in, it must have an output
+// location just like the one given at startup
+func (s *Session) SetWorkGroup(name string) error {
+	workGroupOkay, err := CheckWorkGroup(name, s.Client, s.Ctx)
+	if err != nil {
+		return err
+	}
+	if !workGroupOkay {
+		return fmt.Errorf("this workgroup '%s' has no default output location specified", name)
+	}
+	s.WorkGroup = name
+	s.prepared = nil
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestPrompt(t *testing.T) {
+	tests := []struct {
+		catalog, database string
+		pending           bool
+		want              string
+	}{
+		{"AwsDataCatalog", "db", false, "AwsDataCatalog.db> "},
+		{"AwsDataCatalog", "db", true, "              ...> "},
+		{"a", "", false, "a.> "},
+		{"a", "", true, "...> "},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		s.Catalog, s.Database = tt.catalog, tt.database
+		if tt.pending {
+			s.splitter.Feed("select 1")
+		}
+		if got := s.Prompt(); got != tt.want {
+			t.Errorf("Prompt() for %s.%s = %q, want %q", tt.catalog, tt.database, got, tt.want)
+		}
+	}
+}
+
+func TestUseDatabase(t *testing.T) {
+	tests := []struct {
+		name              string
+		catalog, database string
+		wantErr           bool
+	}{
+		{"sales", "AwsDataCatalog", "sales", false},
+		{"other.sales", "other", "sales", false},
+		{"", "AwsDataCatalog", "db", true},
+		{".sales", "AwsDataCatalog", "db", true},
+		{"other.", "AwsDataCatalog", "db", true},
+	}
+	for _, tt := range tests {
+		s, _ := newTestSession(t)
+		err := s.UseDatabase(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UseDatabase(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if s.Catalog != tt.catalog || s.Database != tt.database {
+			t.Errorf("UseDatabase(%q) gave %s.%s, want %s.%s", tt.name, s.Catalog, s.Database, tt.catalog, tt.database)
+		}
+	}
+}
+
+func TestEmptyCatalogRejected(t *testing.T) {
+	s, _ := newTestSession(t)
+	if _, err := s.ProcessCommand(".catalog "); err == nil {
+		t.Error("empty catalog was accepted")
+	}
+	if s.Catalog != DefaultCatalog {
+		t.Errorf("Catalog = %q, want %q", s.Catalog, DefaultCatalog)
+	}
+}
+
+func TestSessionsAreIndependent(t *testing.T) {
+	fake := NewFakeAthena()
+	fake.WorkGroups["primary"] = "s3://fake-athena-results/"
+	fake.WorkGroups["other"] = "s3://fake-athena-other/"
+	fake.AddQuery("select slow", &FakeQuery{
+		States: []types.QueryExecutionState{types.QueryExecutionStateRunning},
+	})
+	first := NewSession(context.Background(), fake, "primary", "db")
+	second := NewSession(context.Background(), fake, "primary", "db")
+	for _, s := range []*Session{first, second} {
+		s.MaxPollInterval = MinPollInterval
+	}
+
+	captureOutput(t, func() {
+		first.SendLine(".use sales")
+		first.SendLine(".workgroup other")
+	})
+	if second.Database != "db" || second.WorkGroup != "primary" {
+		t.Errorf("second session uses %s in %s, want db in primary", second.Database, second.WorkGroup)
+	}
+
+	// Ctrl-C in one session leaves a query in the other alone
+	captureOutput(t, func() {
+		interruptWhenRunning(first)
+		first.SendLine("select slow;")
+	})
+	if second.Interrupt() {
+		t.Error("second session had a query to interrupt")
+	}
+	captureOutput(t, func() {
+		second.SendLine("select 1;")
+	})
+	if !first.cancelled || second.cancelled {
+		t.Errorf("cancelled = %v and %v, want only the first", first.cancelled, second.cancelled)
+	}
+
+	if len(fake.Started) != 2 {
+		t.Fatalf("%d queries started, want 2", len(fake.Started))
+	}
+	for i, want := range []struct{ database, workGroup string }{{"sales", "other"}, {"db", "primary"}} {
+		started := fake.Started[i]
+		database := aws.ToString(started.QueryExecutionContext.Database)
+		workGroup := aws.ToString(started.WorkGroup)
+		if database != want.database || workGroup != want.workGroup {
+			t.Errorf("query %d ran in %s and %s, want %s and %s", i, database, workGroup, want.database, want.workGroup)
+		}
+	}
+}
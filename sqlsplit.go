@@ -0,0 +1,152 @@
+package main
+
+import (
+	"strings"
+)
+
+type splitState int
+
+const (
+	stateNormal splitState = iota
+	stateSingleQuote
+	stateDoubleQuote
+	stateBacktick
+	stateLineComment
+	stateBlockComment
+)
+
+// StatementSplitter splits SQL input into statements on ';', it is fed one
+// line at a time and understands string literals, quoted identifiers and
+// comments so a ';' inside any of them does not end the statement
+type StatementSplitter struct {
+	buf        strings.Builder
+	state      splitState
+	hasContent bool // seen something other than whitespace and comments
+	commentAt  int  // where a -- comment starts in the last line fed, or -1
+}
+
+// Feed adds a line of input and returns the statements it completes, without
+// their trailing ';'
+func (sp *StatementSplitter) Feed(line string) []string {
+	var statements []string
+	sp.commentAt = -1
+	if sp.buf.Len() > 0 {
+		sp.buf.WriteByte('\n')
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		var next byte
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+
+		switch sp.state {
+		case stateNormal:
+			switch {
+			case c == ';':
+				if sp.hasContent {
+					statements = append(statements, strings.TrimSpace(sp.buf.String()))
+				}
+				sp.Reset()
+				continue
+			case c == '-' && next == '-':
+				sp.state = stateLineComment
+				sp.commentAt = i
+			case c == '/' && next == '*':
+				sp.state = stateBlockComment
+				sp.buf.WriteByte(c)
+				i++
+				c = next
+			case c == '\'':
+				sp.state = stateSingleQuote
+			case c == '"':
+				sp.state = stateDoubleQuote
+			case c == '`':
+				sp.state = stateBacktick
+			}
+			if sp.state != stateLineComment && sp.state != stateBlockComment && c != ' ' && c != '\t' && c != '\r' {
+				sp.hasContent = true
+			}
+		case stateSingleQuote, stateDoubleQuote, stateBacktick:
+			// a doubled quote is an escaped quote so it is handled by leaving
+			// and immediately entering the literal again
+			if c == sp.closingQuote() {
+				sp.state = stateNormal
+			}
+		case stateBlockComment:
+			if c == '*' && next == '/' {
+				sp.state = stateNormal
+				sp.buf.WriteByte(c)
+				i++
+				c = next
+			}
+		}
+		sp.buf.WriteByte(c)
+	}
+
+	// line comments end with the line
+	if sp.state == stateLineComment {
+		sp.state = stateNormal
+	}
+	// a line of only comments is not the start of a statement
+	if !sp.hasContent && sp.state == stateNormal {
+		sp.Reset()
+	}
+	return statements
+}
+
+func (sp *StatementSplitter) closingQuote() byte {
+	switch sp.state {
+	case stateSingleQuote:
+		return '\''
+	case stateDoubleQuote:
+		return '"'
+	}
+	return '`'
+}
+
+// Pending reports if there is an unfinished statement
+func (sp *StatementSplitter) Pending() bool {
+	return sp.hasContent || sp.InLiteral()
+}
+
+// InLiteral reports if the input so far ends inside a string, quoted
+// identifier or block comment
+func (sp *StatementSplitter) InLiteral() bool {
+	return sp.state != stateNormal && sp.state != stateLineComment
+}
+
+// Text returns the unfinished statement
+func (sp *StatementSplitter) Text() string {
+	return sp.buf.String()
+}
+
+// Reset discards any unfinished statement
+func (sp *StatementSplitter) Reset() {
+	sp.buf.Reset()
+	sp.state = stateNormal
+	sp.hasContent = false
+}
+
+// JoinLines joins lines of SQL into a single line, dropping -- comments which
+// would otherwise swallow the lines after them.  It returns false if a line
+// ends inside a string or quoted identifier, joining those would change them
+func JoinLines(lines []string) (string, bool) {
+	var sp StatementSplitter
+	var out []string
+	for i, line := range lines {
+		sp.Feed(line)
+		if sp.commentAt >= 0 {
+			line = line[:sp.commentAt]
+		}
+		switch sp.state {
+		case stateSingleQuote, stateDoubleQuote, stateBacktick:
+			if i < len(lines)-1 {
+				return "", false
+			}
+		}
+		out = append(out, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(out, " ")), true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatementSplitter(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		statements []string
+		pending    bool
+	}{
+		{"single", []string{"select 1;"}, []string{"select 1"}, false},
+		{"several on a line", []string{"select 1; select 2;"}, []string{"select 1", "select 2"}, false},
+		{"over lines", []string{"select *", "from t;"}, []string{"select *\nfrom t"}, false},
+		{"unfinished", []string{"select 1"}, nil, true},
+		{"semicolon in string", []string{"select ';' from t;"}, []string{"select ';' from t"}, false},
+		{"escaped quote", []string{"select 'it''s;' from t;"}, []string{"select 'it''s;' from t"}, false},
+		{"semicolon in identifier", []string{`select "a;b", ` + "`c;d`" + ` from t;`}, []string{`select "a;b", ` + "`c;d`" + ` from t`}, false},
+		{"line comment", []string{"select 1 -- not the end;", "from t;"}, []string{"select 1 -- not the end;\nfrom t"}, false},
+		{"block comment", []string{"select /* ; */ 1;"}, []string{"select /* ; */ 1"}, false},
+		{"multi-line string", []string{"select 'a", "b;' from t;"}, []string{"select 'a\nb;' from t"}, false},
+		{"inside string", []string{"select 'a"}, nil, true},
+		{"comment only", []string{"-- just a comment"}, nil, false},
+		{"empty statement", []string{";"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sp StatementSplitter
+			var statements []string
+			for _, line := range tt.lines {
+				statements = append(statements, sp.Feed(line)...)
+			}
+			if !reflect.DeepEqual(statements, tt.statements) {
+				t.Errorf("statements %q, want %q", statements, tt.statements)
+			}
+			if sp.Pending() != tt.pending {
+				t.Errorf("Pending() = %v, want %v", sp.Pending(), tt.pending)
+			}
+		})
+	}
+}
+
+func TestSplitterInLiteral(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"select 'a", true},
+		{`select "a`, true},
+		{"select /* a", true},
+		{"select 1 -- a", false},
+		{"select 'a'", false},
+	}
+	for _, tt := range tests {
+		var sp StatementSplitter
+		sp.Feed(tt.line)
+		if got := sp.InLiteral(); got != tt.want {
+			t.Errorf("InLiteral() after %q = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestJoinLines(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  string
+		ok    bool
+	}{
+		{[]string{"select *", "from t;"}, "select * from t;", true},
+		{[]string{"select *  ", "  from t -- the table", "where a = 1;"}, "select *   from t where a = 1;", true},
+		{[]string{"select '--' as a", "from t;"}, "select '--' as a from t;", true},
+		{[]string{"select /* a", "b */ 1;"}, "select /* a b */ 1;", true},
+		{[]string{"select 'a  ", "b' from t;"}, "", false},
+		{[]string{`select "a`, `b" from t;`}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := JoinLines(tt.lines)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("JoinLines(%q) = %q, %v, want %q, %v", tt.lines, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// TimelineWidth is the width in characters of the bars drawn by .timeline
+const TimelineWidth int = 50
+
+// timelinePhase is one part of the life of a query, start and duration are
+// in milliseconds from when it was submitted
+type timelinePhase struct {
+	name     string
+	start    int64
+	duration int64
+}
+
+func formatMillis(millis *int64) string {
+	if millis == nil {
+		return "-"
+	}
+	return (time.Duration(*millis) * time.Millisecond).String()
+}
+
+// PrintFullStats prints everything Athena reports about how a query ran
+func PrintFullStats(exec *types.QueryExecution, cost float64) {
+	stats := exec.Statistics
+	if stats == nil {
+		stats = &types.QueryExecutionStatistics{}
+	}
+	engine := "-"
+	if exec.EngineVersion != nil {
+		engine = fmt.Sprintf("%s (selected %s)", aws.ToString(exec.EngineVersion.EffectiveEngineVersion), aws.ToString(exec.EngineVersion.SelectedEngineVersion))
+	}
+	manifest := aws.ToString(stats.DataManifestLocation)
+	if manifest == "" {
+		manifest = "-"
+	}
+	reuse := "not reused"
+	if stats.ResultReuseInformation != nil && stats.ResultReuseInformation.ReusedPreviousResult {
+		reuse = "reused a previous result"
+	}
+
+	fmt.Println("Stats:")
+	fmt.Printf("  %-25s%s\n", "Workgroup:", aws.ToString(exec.WorkGroup))
+	fmt.Printf("  %-25s%s\n", "Engine version:", engine)
+	fmt.Printf("  %-25s%s\n", "Data scanned:", FormatBytes(aws.ToInt64(stats.DataScannedInBytes)))
+	fmt.Printf("  %-25s%s\n", "Estimated cost:", FormatCost(cost))
+	fmt.Printf("  %-25s%s\n", "Queue time:", formatMillis(stats.QueryQueueTimeInMillis))
+	fmt.Printf("  %-25s%s\n", "Query planning time:", formatMillis(stats.QueryPlanningTimeInMillis))
+	fmt.Printf("  %-25s%s\n", "Engine execution time:", formatMillis(stats.EngineExecutionTimeInMillis))
+	fmt.Printf("  %-25s%s\n", "Service processing time:", formatMillis(stats.ServiceProcessingTimeInMillis))
+	fmt.Printf("  %-25s%s\n", "Total execution time:", formatMillis(stats.TotalExecutionTimeInMillis))
+	fmt.Printf("  %-25s%s\n", "Data manifest:", manifest)
+	fmt.Printf("  %-25s%s\n", "Result reuse:", reuse)
+}
+
+// timelinePhases splits the time a query took into phases, planning is done
+// by the engine so it is the start of the engine execution time
+func timelinePhases(stats *types.QueryExecutionStatistics) []timelinePhase {
+	queue := aws.ToInt64(stats.QueryQueueTimeInMillis)
+	planning := aws.ToInt64(stats.QueryPlanningTimeInMillis)
+	engine := aws.ToInt64(stats.EngineExecutionTimeInMillis)
+	service := aws.ToInt64(stats.ServiceProcessingTimeInMillis)
+	if planning > engine {
+		planning = engine
+	}
+	return []timelinePhase{
+		{"Queued", 0, queue},
+		{"Planning", queue, planning},
+		{"Executing", queue + planning, engine - planning},
+		{"Processing", queue + engine, service},
+	}
+}
+
+// RenderTimeline draws a bar for each phase of a query, scaled so the whole
+// query fits in TimelineWidth characters
+func RenderTimeline(stats *types.QueryExecutionStatistics) []string {
+	phases := timelinePhases(stats)
+	last := phases[len(phases)-1]
+	total := last.start + last.duration
+	if totalTime := aws.ToInt64(stats.TotalExecutionTimeInMillis); totalTime > total {
+		total = totalTime
+	}
+
+	var lines []string
+	for _, phase := range phases {
+		start, width := 0, 0
+		if total > 0 {
+			start = int(phase.start * int64(TimelineWidth) / total)
+			end := int((phase.start + phase.duration) * int64(TimelineWidth) / total)
+			width = end - start
+			// short phases still get a mark so they can be seen
+			if width == 0 && phase.duration > 0 && start < TimelineWidth {
+				width = 1
+			}
+		}
+		bar := strings.Repeat(" ", start) + strings.Repeat("#", width)
+		lines = append(lines, fmt.Sprintf("%-11s|%-*s| %s", phase.name, TimelineWidth, bar, formatMillis(&phase.duration)))
+	}
+	lines = append(lines, fmt.Sprintf("%-11s %*s  %s", "Total", TimelineWidth, "", formatMillis(&total)))
+	return lines
+}
+
+// ShowTimeline prints the timeline of any query in the workgroup, not just the
+// ones run in this session
+func (s *Session) ShowTimeline(execId string) error {
+	exec, err := GetQueryExecution(execId, s.Client, s.Ctx)
+	if err != nil {
+		return err
+	}
+	if exec.Statistics == nil {
+		return fmt.Errorf("no statistics are available for query %s", execId)
+	}
+	fmt.Printf("Query %s %s\n", execId, exec.Status.State)
+	for _, line := range RenderTimeline(exec.Statistics) {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+func TestRenderTimeline(t *testing.T) {
+	type bar struct {
+		start, width int
+		duration     string
+	}
+	tests := []struct {
+		name  string
+		stats types.QueryExecutionStatistics
+		bars  []bar // queued, planning, executing and processing
+		total string
+	}{
+		{
+			name: "every phase",
+			stats: types.QueryExecutionStatistics{
+				QueryQueueTimeInMillis:        aws.Int64(1000),
+				QueryPlanningTimeInMillis:     aws.Int64(500),
+				EngineExecutionTimeInMillis:   aws.Int64(3000),
+				ServiceProcessingTimeInMillis: aws.Int64(500),
+				TotalExecutionTimeInMillis:    aws.Int64(4500),
+			},
+			bars:  []bar{{0, 11, "1s"}, {11, 5, "500ms"}, {16, 28, "2.5s"}, {44, 6, "500ms"}},
+			total: "4.5s",
+		},
+		{
+			name:  "no statistics",
+			stats: types.QueryExecutionStatistics{},
+			bars:  []bar{{0, 0, "0s"}, {0, 0, "0s"}, {0, 0, "0s"}, {0, 0, "0s"}},
+			total: "0s",
+		},
+		{
+			name: "short phase still shown",
+			stats: types.QueryExecutionStatistics{
+				QueryQueueTimeInMillis:      aws.Int64(0),
+				EngineExecutionTimeInMillis: aws.Int64(10),
+				TotalExecutionTimeInMillis:  aws.Int64(10000),
+			},
+			bars:  []bar{{0, 0, "0s"}, {0, 0, "0s"}, {0, 1, "10ms"}, {0, 0, "0s"}},
+			total: "10s",
+		},
+		{
+			name: "planning longer than the engine time",
+			stats: types.QueryExecutionStatistics{
+				QueryQueueTimeInMillis:      aws.Int64(0),
+				QueryPlanningTimeInMillis:   aws.Int64(2000),
+				EngineExecutionTimeInMillis: aws.Int64(1000),
+			},
+			bars:  []bar{{0, 0, "0s"}, {0, 50, "1s"}, {50, 0, "0s"}, {50, 0, "0s"}},
+			total: "1s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := RenderTimeline(&tt.stats)
+			if len(lines) != len(tt.bars)+1 {
+				t.Fatalf("%d lines, want %d:\n%s", len(lines), len(tt.bars)+1, strings.Join(lines, "\n"))
+			}
+			for i, want := range tt.bars {
+				wantBar := strings.Repeat(" ", want.start) + strings.Repeat("#", want.width)
+				wantLine := fmt.Sprintf("|%-*s| %s", TimelineWidth, wantBar, want.duration)
+				if !strings.HasSuffix(lines[i], wantLine) {
+					t.Errorf("line %d is %q, want it to end %q", i+1, lines[i], wantLine)
+				}
+			}
+			if total := lines[len(lines)-1]; !strings.HasPrefix(total, "Total") || !strings.HasSuffix(total, " "+tt.total) {
+				t.Errorf("total line is %q, want %s", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestPrintFullStats(t *testing.T) {
+	tests := []struct {
+		name string
+		exec types.QueryExecution
+		want []string
+	}{
+		{
+			name: "all reported",
+			exec: types.QueryExecution{
+				WorkGroup: aws.String("primary"),
+				EngineVersion: &types.EngineVersion{
+					SelectedEngineVersion:  aws.String("AUTO"),
+					EffectiveEngineVersion: aws.String("Athena engine version 3"),
+				},
+				Statistics: &types.QueryExecutionStatistics{
+					DataScannedInBytes:          aws.Int64(2048),
+					EngineExecutionTimeInMillis: aws.Int64(1500),
+					DataManifestLocation:        aws.String("s3://bucket/manifest.csv"),
+					ResultReuseInformation:      &types.ResultReuseInformation{ReusedPreviousResult: true},
+				},
+			},
+			want: []string{
+				"Workgroup:               primary",
+				"Engine version:          Athena engine version 3 (selected AUTO)",
+				"Data scanned:            " + FormatBytes(2048),
+				"Engine execution time:   1.5s",
+				"Queue time:              -",
+				"Data manifest:           s3://bucket/manifest.csv",
+				"Result reuse:            reused a previous result",
+			},
+		},
+		{
+			name: "nothing reported",
+			exec: types.QueryExecution{},
+			want: []string{
+				"Engine version:          -",
+				"Data scanned:            " + FormatBytes(0),
+				"Total execution time:    -",
+				"Data manifest:           -",
+				"Result reuse:            not reused",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureOutput(t, func() {
+				PrintFullStats(&tt.exec, 0.01)
+			})
+			for _, want := range tt.want {
+				if !strings.Contains(out, "  "+want+"\n") {
+					t.Errorf("printed:\n%s\nwant the line %q", out, want)
+				}
+			}
+		})
+	}
+}